@@ -5,6 +5,8 @@
 package sabi
 
 import (
+	"context"
+	"sort"
 	"sync"
 
 	"github.com/sttk/sabi/errs"
@@ -17,6 +19,22 @@ type AsyncGroup interface {
 	Add(fn func() errs.Err)
 }
 
+// ContextOf is the function to get the context.Context which the argument
+// AsyncGroup carries.
+// An AsyncGroup passed to DaxSrc#Setup, DaxConn#Commit, DaxConn#Rollback and
+// DaxConn#ForceBack carries the context.Context of the setup or the
+// transaction, so that those methods can observe its cancellation and
+// deadline.
+// If the argument AsyncGroup carries no context.Context, this function
+// returns context.Background().
+func ContextOf(ag AsyncGroup) context.Context {
+	c, ok := ag.(interface{ context() context.Context })
+	if ok {
+		return c.context()
+	}
+	return context.Background()
+}
+
 type errEntry[N comparable] struct {
 	name N
 	err  errs.Err
@@ -29,6 +47,7 @@ type asyncGroupAsync[N comparable] struct {
 	errLast *errEntry[N]
 	mutex   sync.Mutex
 	name    N
+	ctx     context.Context
 }
 
 func (ag *asyncGroupAsync[N]) Add(fn func() errs.Err) {
@@ -44,6 +63,13 @@ func (ag *asyncGroupAsync[N]) Add(fn func() errs.Err) {
 	}(ag.name)
 }
 
+func (ag *asyncGroupAsync[N]) context() context.Context {
+	if ag.ctx == nil {
+		return context.Background()
+	}
+	return ag.ctx
+}
+
 func (ag *asyncGroupAsync[N]) wait() {
 	ag.wg.Wait()
 }
@@ -71,10 +97,75 @@ func (ag *asyncGroupAsync[N]) makeErrs() map[N]errs.Err {
 	return m
 }
 
+// causesOf returns the errs.Err(s) in the argument map as errors in order of
+// the keys, which are passed as causes of an errs.Err of an error reason
+// aggregating them.
+func causesOf[N ~int | ~string](m map[N]errs.Err) []error {
+	keys := make([]N, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	causes := make([]error, len(keys))
+	for i, key := range keys {
+		causes[i] = m[key]
+	}
+	return causes
+}
+
 type asyncGroupSync struct {
 	err errs.Err
+	ctx context.Context
 }
 
 func (ag *asyncGroupSync) Add(fn func() errs.Err) {
 	ag.err = fn()
 }
+
+func (ag *asyncGroupSync) context() context.Context {
+	if ag.ctx == nil {
+		return context.Background()
+	}
+	return ag.ctx
+}
+
+type asyncGroupNamed[N comparable] struct {
+	name  N
+	ctx   context.Context
+	wg    sync.WaitGroup
+	mutex sync.Mutex
+	err   errs.Err
+}
+
+func (ag *asyncGroupNamed[N]) Add(fn func() errs.Err) {
+	ag.wg.Add(1)
+	go func() {
+		defer ag.wg.Done()
+		err := fn()
+		if err.IsNotOk() {
+			ag.mutex.Lock()
+			defer ag.mutex.Unlock()
+			if ag.err.IsOk() {
+				ag.err = err
+			}
+		}
+	}()
+}
+
+func (ag *asyncGroupNamed[N]) context() context.Context {
+	if ag.ctx == nil {
+		return context.Background()
+	}
+	return ag.ctx
+}
+
+func (ag *asyncGroupNamed[N]) wait() {
+	ag.wg.Wait()
+}
+
+func (ag *asyncGroupNamed[N]) error() errs.Err {
+	ag.mutex.Lock()
+	defer ag.mutex.Unlock()
+	return ag.err
+}
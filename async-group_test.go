@@ -1,6 +1,7 @@
 package sabi
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -155,3 +156,18 @@ func TestAsyncGroup_asyncGroupAsync_multipleErrors(t *testing.T) {
 	assert.True(t, exec1)
 	assert.True(t, exec2)
 }
+
+func TestAsyncGroup_ContextOf(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	var agSync asyncGroupSync
+	assert.Equal(t, ContextOf(&agSync), context.Background())
+	agSync.ctx = ctx
+	assert.Equal(t, ContextOf(&agSync).Value(key{}), "value")
+
+	var agAsync asyncGroupAsync[string]
+	assert.Equal(t, ContextOf(&agAsync), context.Background())
+	agAsync.ctx = ctx
+	assert.Equal(t, ContextOf(&agAsync).Value(key{}), "value")
+}
@@ -5,7 +5,9 @@
 package sabi
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	om "github.com/sttk/orderedmap"
 
@@ -22,6 +24,15 @@ type /* error reasons */ (
 		Errors map[string]errs.Err
 	}
 
+	// SetupTimedOut is the error reason which indicates that a global DaxSrc
+	// did not finish its synchronous and asynchronous Setup before the deadline
+	// given by WithDaxSrcSetupTimeout or WithSetupTimeout, or before the
+	// context.Context passed to SetupCtx was done.
+	// The field Name is the registered name of the DaxSrc timed out.
+	SetupTimedOut struct {
+		Name string
+	}
+
 	// FailToSetupLocalDaxSrc is the error reason which indicates that a local
 	// DaxSrc failed to set up.
 	// The field Name is the registered name of the DaxSrc failed.
@@ -29,6 +40,23 @@ type /* error reasons */ (
 		Name string
 	}
 
+	// DaxSrcDependenciesAreCyclic is the error reason which indicates that
+	// dependencies among global DaxSrc(s) declared with Uses function form a
+	// cycle.
+	// The field Names is the registered names of DaxSrc(s) which cannot be
+	// ordered because of the cycle.
+	DaxSrcDependenciesAreCyclic struct {
+		Names []string
+	}
+
+	// DependedDaxSrcIsNotFound is the error reason which indicates that a
+	// global DaxSrc depends on a DaxSrc which is not registered.
+	// The field Name is the registered name of the depending DaxSrc, and the
+	// field DependedName is the name of the DaxSrc not found.
+	DependedDaxSrcIsNotFound struct {
+		Name, DependedName string
+	}
+
 	// DaxSrcIsNotFound is the error reason which indicates that a specified
 	// DaxSrc is not found.
 	// The field Name is the registered name of the DaxSrc not found.
@@ -76,6 +104,54 @@ type /* error reasons */ (
 	FailToCastDaxBase struct {
 		FromType, ToType string
 	}
+
+	// FailToPrepareDaxConn is the error reason which indicates that some
+	// connections failed to prepare for commit in two-phase commit.
+	// The field Errors is the map of which keys are registered names of DaxConn
+	// which failed to prepare, and of which values are errs.Err(s) having their
+	// error reasons.
+	FailToPrepareDaxConn struct {
+		Errors map[string]errs.Err
+	}
+
+	// FailToCloseGlobalDaxSrcs is the error reason which indicates that some
+	// global DaxSrc(s) failed to close or did not finish closing in time.
+	// The field Errors is the map of which keys are the registered names of
+	// DaxSrc(s) that failed, and of which values are errs.Err having their error
+	// reasons.
+	FailToCloseGlobalDaxSrcs struct {
+		Errors map[string]errs.Err
+	}
+
+	// FailToCloseLocalDaxSrcs is the error reason which indicates that some
+	// local DaxSrc(s) failed to close.
+	// The field Errors is the map of which keys are the registered names of
+	// DaxSrc(s) that failed, and of which values are errs.Err having their error
+	// reasons.
+	FailToCloseLocalDaxSrcs struct {
+		Errors map[string]errs.Err
+	}
+
+	// FailToCloseLocalDaxSrc is the error reason which indicates that a local
+	// DaxSrc failed to close.
+	// The field Name is the registered name of the DaxSrc failed.
+	FailToCloseLocalDaxSrc struct {
+		Name string
+	}
+
+	// FailToCloseDaxConns is the error reason which indicates that some
+	// connections failed to close at the end of a transaction.
+	// The field Errors is the map of which keys are registered names of DaxConn
+	// which failed to close, and of which values are errs.Err(s) having their
+	// error reasons.
+	FailToCloseDaxConns struct {
+		Errors map[string]errs.Err
+	}
+
+	// TxnIsCanceled is the error reason which indicates that a transaction is
+	// canceled or its deadline is exceeded before it is committed.
+	// An Err of this reason has the error of context.Context as its cause.
+	TxnIsCanceled struct{}
 )
 
 // DaxConn is the interface that represents a connection to a data store, and
@@ -98,6 +174,32 @@ type DaxConn interface {
 	Close()
 }
 
+// Preparable is the optional interface which a DaxConn implements to take
+// part in two-phase commit.
+//
+// Prepare is the method to prepare updates in a transaction for commit, and
+// after this method succeeds, updates must be able to be committed certainly
+// with CommitPrepared or discarded with RollbackPrepared.
+// CommitPrepared is the method to commit prepared updates.
+// RollbackPrepared is the method to discard prepared updates.
+// If these procedures are asynchronous, the argument AsyncGroup(s) are used to
+// process them.
+//
+// When a transaction is committed, Prepare methods of all Preparable DaxConn(s)
+// are called first.
+// If all of them succeed, Commit methods of other DaxConn(s) are called, and
+// then CommitPrepared methods of Preparable DaxConn(s) are called.
+// If one of them fails, no DaxConn is committed, and prepared DaxConn(s) are
+// discarded with RollbackPrepared.
+// If CommitPrepared of a DaxConn fails, prepared DaxConn(s) of which
+// CommitPrepared succeeded are forced back with ForceBack, even if their
+// IsCommitted methods return false.
+type Preparable interface {
+	Prepare(ag AsyncGroup) errs.Err
+	CommitPrepared(ag AsyncGroup) errs.Err
+	RollbackPrepared(ag AsyncGroup)
+}
+
 // DaxSrc is the interface that represents a data source which creates
 // connections to a data store like database, etc.
 // This interface declares three methods: Setup, Close, and CreateDaxConn.
@@ -115,13 +217,45 @@ type DaxSrc interface {
 	CreateDaxConn() (DaxConn, errs.Err)
 }
 
+// ErrCloser is the optional interface which a DaxSrc or a DaxConn implements
+// to report an error of closing.
+//
+// If a DaxSrc or a DaxConn implements this interface, CloseWithErr method is
+// called instead of Close method, and the errs.Err returned by it is reported
+// from the function or the method which closes it.
+type ErrCloser interface {
+	CloseWithErr() errs.Err
+}
+
+func closeWithErr(c interface{ Close() }) errs.Err {
+	ec, ok := c.(ErrCloser)
+	if ok {
+		return ec.CloseWithErr()
+	}
+	c.Close()
+	return errs.Ok()
+}
+
+// CtxDaxSrc is the optional interface which a DaxSrc implements to create
+// DaxConn objects with the context.Context of a transaction.
+//
+// If a DaxSrc implements this interface, CreateDaxConnCtx method is called
+// instead of CreateDaxConn method, and it receives the context.Context given
+// to TxnCtx function.
+type CtxDaxSrc interface {
+	CreateDaxConnCtx(ctx context.Context) (DaxConn, errs.Err)
+}
+
 type daxSrcEntry struct {
 	name    string
 	ds      DaxSrc
+	deps    []string
 	local   bool
 	deleted bool
 	prev    *daxSrcEntry
 	next    *daxSrcEntry
+	mutex   sync.Mutex
+	usage   *daxSrcUsage
 }
 
 type daxSrcEntryList struct {
@@ -129,31 +263,83 @@ type daxSrcEntryList struct {
 	last *daxSrcEntry
 }
 
-var (
-	isGlobalDaxSrcsFixed  bool = false
-	globalDaxSrcEntryList daxSrcEntryList
-)
-
 // Uses is the method that registers a global DaxSrc with its name to enable to
 // use DaxConn created by the argument DaxSrc in all transactions.
 //
+// The optional arguments deps are the names of other global DaxSrc(s) which
+// the argument DaxSrc depends on.
+// Setup function sets up a DaxSrc after all DaxSrc(s) it depends on have been
+// set up, and Close function closes it before them.
+//
 // If a DaxSrc is tried to register with a name already registered, it is
 // ignored and a DaxSrc registered with the same name first is used.
 // And this method ignore adding new DaxSrc(s) after Setup or beginning of Txn.
-func Uses(name string, ds DaxSrc) {
-	if isGlobalDaxSrcsFixed {
+func Uses(name string, ds DaxSrc, deps ...string) {
+	defaultRegistry.Uses(name, ds, deps...)
+}
+
+// Uses is the method that registers a global DaxSrc to this Registry.
+// See Uses function for details.
+func (reg *Registry) Uses(name string, ds DaxSrc, deps ...string) {
+	if reg.isDaxSrcsFixed {
 		return
 	}
 
-	ent := &daxSrcEntry{name: name, ds: ds}
+	ent := &daxSrcEntry{name: name, ds: ds, deps: deps}
 
-	if globalDaxSrcEntryList.head == nil {
-		globalDaxSrcEntryList.head = ent
-		globalDaxSrcEntryList.last = ent
+	if reg.daxSrcEntryList.head == nil {
+		reg.daxSrcEntryList.head = ent
+		reg.daxSrcEntryList.last = ent
 	} else {
-		ent.prev = globalDaxSrcEntryList.last
-		globalDaxSrcEntryList.last.next = ent
-		globalDaxSrcEntryList.last = ent
+		ent.prev = reg.daxSrcEntryList.last
+		reg.daxSrcEntryList.last.next = ent
+		reg.daxSrcEntryList.last = ent
+	}
+}
+
+// SetupOption is the function type to configure how Setup function and
+// SetupCtx function set up global DaxSrc(s).
+type SetupOption func(cfg *setupConfig)
+
+type setupConfig struct {
+	concurrency   int
+	timeout       time.Duration
+	daxSrcTimeout time.Duration
+}
+
+// WithSetupConcurrency is the function that creates a SetupOption to set up
+// independent global DaxSrc(s) concurrently.
+// The argument n is the maximum number of DaxSrc(s) of which Setup methods
+// run at the same time.
+// If n is less than or equal to 1, DaxSrc(s) are set up one by one, which is
+// the default.
+func WithSetupConcurrency(n int) SetupOption {
+	return func(cfg *setupConfig) {
+		cfg.concurrency = n
+	}
+}
+
+// WithDaxSrcSetupTimeout is the function that creates a SetupOption to give
+// each global DaxSrc a deadline of its set up.
+// If a DaxSrc does not finish its synchronous and asynchronous Setup when the
+// argument duration elapses from the start of its Setup, the DaxSrc is
+// regarded as failed with the error reason: SetupTimedOut.
+// The context.Context which can be got with ContextOf function in
+// DaxSrc#Setup is done at that deadline, so a DaxSrc should observe it to
+// stop setting up.
+func WithDaxSrcSetupTimeout(d time.Duration) SetupOption {
+	return func(cfg *setupConfig) {
+		cfg.daxSrcTimeout = d
+	}
+}
+
+// WithSetupTimeout is the function that creates a SetupOption to give the
+// whole set up of global DaxSrc(s) a deadline.
+// DaxSrc(s) which do not finish their Setup when the argument duration
+// elapses are regarded as failed with the error reason: SetupTimedOut.
+func WithSetupTimeout(d time.Duration) SetupOption {
+	return func(cfg *setupConfig) {
+		cfg.timeout = d
 	}
 }
 
@@ -161,47 +347,374 @@ func Uses(name string, ds DaxSrc) {
 // This function forbids adding more global DaxSrc(s), and calls each Setup
 // method of all registered DaxSrc(s).
 //
+// DaxSrc(s) are set up in order of their dependencies declared with Uses
+// function.
+// Setup methods of DaxSrc(s) which depend on other DaxSrc(s) are called after
+// synchronous and asynchronous Setup of those DaxSrc(s) are finished.
+// If the dependencies form a cycle or refer to an unregistered DaxSrc, this
+// function sets up no DaxSrc and returns an errs.Err of the reason:
+// DaxSrcDependenciesAreCyclic or DependedDaxSrcIsNotFound.
+//
 // If one of DaxSrc(s) fails to execute synchronous Setup, this function stops
 // other setting up and returns an errs.Err containing the error reason of
 // that failure.
+// When DaxSrc(s) are set up concurrently, Setup methods already running are
+// waited for, and errors of them are also contained.
 //
 // If one of DaxSrc(s) fails to execute asynchronous Setup, this function
 // continue to other setting up and returns an errs.Err containing the error
 // reason of that failure and other errors if any.
-func Setup() errs.Err {
-	isGlobalDaxSrcsFixed = true
-	errs.FixCfg()
+//
+// If one of DaxSrc(s) does not finish its Setup before a deadline, this
+// function does not wait for it any more and returns an errs.Err containing
+// the error reason: SetupTimedOut.
+// DaxSrc(s) which finished their Setup are closed before this function
+// returns, and a timed out DaxSrc is closed when its Setup finishes.
+//
+// The behavior of setting up can be changed with the argument SetupOption(s).
+func Setup(opts ...SetupOption) errs.Err {
+	return defaultRegistry.SetupCtx(context.Background(), opts...)
+}
 
-	var ag asyncGroupAsync[string]
+// Setup is the method that sets up global DaxSrc(s) of this Registry.
+// See Setup function for details.
+func (reg *Registry) Setup(opts ...SetupOption) errs.Err {
+	return reg.SetupCtx(context.Background(), opts...)
+}
 
-	for ent := globalDaxSrcEntryList.head; ent != nil; ent = ent.next {
-		ag.name = ent.name
-		err := ent.ds.Setup(&ag)
-		if err.IsNotOk() {
-			ag.wait()
-			Close()
-			ag.addErr(ag.name, err)
-			return errs.New(FailToSetupGlobalDaxSrcs{Errors: ag.makeErrs()})
-		}
+// SetupCtx is the function that does the same as Setup function, but passes
+// the argument context.Context to each DaxSrc through the AsyncGroup.
+// The context.Context can be got with ContextOf function in DaxSrc#Setup.
+// If the context.Context is done during the set up, DaxSrc(s) not finished
+// are regarded as timed out.
+func SetupCtx(ctx context.Context, opts ...SetupOption) errs.Err {
+	return defaultRegistry.SetupCtx(ctx, opts...)
+}
+
+// SetupCtx is the method that does the same as Setup method, but passes the
+// argument context.Context to each DaxSrc.
+// See SetupCtx function for details.
+func (reg *Registry) SetupCtx(ctx context.Context, opts ...SetupOption) errs.Err {
+	reg.fix()
+
+	cfg := setupConfig{concurrency: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
 	}
 
-	ag.wait()
+	levels, err := reg.sortDaxSrcEntries()
+	if err.IsNotOk() {
+		return err
+	}
 
-	if ag.hasErr() {
-		Close()
-		return errs.New(FailToSetupGlobalDaxSrcs{Errors: ag.makeErrs()})
+	start := time.Now()
+	defer func() {
+		reg.metrics.ObserveHistogram(MetricSetupDuration, nil,
+			time.Since(start).Seconds())
+	}()
+
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+
+	for _, level := range levels {
+		errMap, timedOut := setupDaxSrcs(ctx, level, cfg, reg.metrics)
+		if len(errMap) > 0 {
+			reg.closeDaxSrcs(timedOut)
+			return errs.New(FailToSetupGlobalDaxSrcs{Errors: errMap}, causesOf(errMap)...)
+		}
 	}
 
 	return errs.Ok()
 }
 
+type daxSrcSetup struct {
+	ent    *daxSrcEntry
+	ag     *asyncGroupNamed[string]
+	cancel context.CancelFunc
+	syncCh chan errs.Err
+	doneCh chan struct{}
+}
+
+func startDaxSrcSetup(
+	ctx context.Context, ent *daxSrcEntry, timeout time.Duration,
+	sink MetricsSink,
+) *daxSrcSetup {
+	s := &daxSrcSetup{
+		ent:    ent,
+		ag:     &asyncGroupNamed[string]{name: ent.name, ctx: ctx},
+		cancel: func() {},
+		syncCh: make(chan errs.Err, 1),
+		doneCh: make(chan struct{}),
+	}
+
+	if timeout > 0 {
+		s.ag.ctx, s.cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	go func() {
+		start := time.Now()
+		s.syncCh <- ent.daxSrc().Setup(s.ag)
+		s.ag.wait()
+		sink.ObserveHistogram(MetricDaxSrcSetupDuration,
+			map[string]string{LabelDaxSrc: ent.name}, time.Since(start).Seconds())
+		close(s.doneCh)
+	}()
+
+	return s
+}
+
+func (s *daxSrcSetup) timedOut(err errs.Err) errs.Err {
+	if err.IsNotOk() {
+		return errs.New(SetupTimedOut{Name: s.ent.name}, err)
+	}
+	return errs.New(SetupTimedOut{Name: s.ent.name}, s.ag.ctx.Err())
+}
+
+// waitSync waits for the synchronous Setup of the DaxSrc, and returns its
+// error and whether it is timed out.
+// A failure after the deadline is also regarded as timed out.
+func (s *daxSrcSetup) waitSync() (errs.Err, bool) {
+	var err errs.Err
+	select {
+	case err = <-s.syncCh:
+	case <-s.ag.ctx.Done():
+		select {
+		case err = <-s.syncCh:
+		default:
+			return s.timedOut(errs.Ok()), true
+		}
+	}
+	if err.IsNotOk() && s.ag.ctx.Err() != nil {
+		return s.timedOut(err), true
+	}
+	return err, false
+}
+
+// waitAsync waits for the asynchronous Setup of the DaxSrc, and returns its
+// error and whether it is timed out.
+func (s *daxSrcSetup) waitAsync() (errs.Err, bool) {
+	select {
+	case <-s.doneCh:
+	case <-s.ag.ctx.Done():
+		select {
+		case <-s.doneCh:
+		default:
+			return s.timedOut(errs.Ok()), true
+		}
+	}
+	defer s.cancel()
+	err := s.ag.error()
+	if err.IsNotOk() && s.ag.ctx.Err() != nil {
+		return s.timedOut(err), true
+	}
+	return err, false
+}
+
+// closeLater closes the DaxSrc after its Setup finishes.
+func (s *daxSrcSetup) closeLater() {
+	go func() {
+		<-s.doneCh
+		s.cancel()
+		closeWithErr(s.ent.daxSrc())
+	}()
+}
+
+// setupDaxSrcs calls Setup methods of the argument DaxSrc entries with
+// the concurrency of the argument setupConfig, and returns errors of them
+// and the set of entries timed out.
+// Once a synchronous Setup fails, Setup methods not yet started are skipped.
+func setupDaxSrcs(
+	ctx context.Context, ents []*daxSrcEntry, cfg setupConfig, sink MetricsSink,
+) (map[string]errs.Err, map[*daxSrcEntry]bool) {
+	errMap := make(map[string]errs.Err)
+	timedOut := make(map[*daxSrcEntry]bool)
+	var started []*daxSrcSetup
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cfg.concurrency)
+
+	for _, ent := range ents {
+		sem <- struct{}{}
+
+		mutex.Lock()
+		failed := len(errMap) > 0
+		if !failed && ctx.Err() != nil {
+			errMap[ent.name] = errs.New(SetupTimedOut{Name: ent.name}, ctx.Err())
+			failed = true
+		}
+		mutex.Unlock()
+		if failed {
+			<-sem
+			break
+		}
+
+		s := startDaxSrcSetup(ctx, ent, cfg.daxSrcTimeout, sink)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err, isTimedOut := s.waitSync()
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err.IsNotOk() {
+				errMap[s.ent.name] = err
+			}
+			if isTimedOut {
+				timedOut[s.ent] = true
+				s.closeLater()
+			} else {
+				started = append(started, s)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for _, s := range started {
+		err, isTimedOut := s.waitAsync()
+		if isTimedOut {
+			timedOut[s.ent] = true
+			s.closeLater()
+			errMap[s.ent.name] = err
+		} else if _, exists := errMap[s.ent.name]; !exists && err.IsNotOk() {
+			errMap[s.ent.name] = err
+		}
+	}
+
+	return errMap, timedOut
+}
+
+// sortDaxSrcEntries sorts global DaxSrc entries topologically by their
+// dependencies, and relinks the entry list of this Registry in the sorted
+// order.
+// The entries are grouped into levels, and entries in a level depend only on
+// entries in preceding levels.
+// Entries without dependencies keep their registration order.
+func (reg *Registry) sortDaxSrcEntries() ([][]*daxSrcEntry, errs.Err) {
+	entMap := make(map[string]*daxSrcEntry)
+	for ent := reg.daxSrcEntryList.last; ent != nil; ent = ent.prev {
+		entMap[ent.name] = ent
+	}
+
+	depMap := make(map[*daxSrcEntry][]*daxSrcEntry)
+	var remains []*daxSrcEntry
+
+	for ent := reg.daxSrcEntryList.head; ent != nil; ent = ent.next {
+		for _, dep := range ent.deps {
+			depEnt, exists := entMap[dep]
+			if !exists {
+				return nil, errs.New(DependedDaxSrcIsNotFound{
+					Name: ent.name, DependedName: dep,
+				})
+			}
+			depMap[ent] = append(depMap[ent], depEnt)
+		}
+		// To keep the first registered entry prior to others with the same name.
+		if first := entMap[ent.name]; first != ent {
+			depMap[ent] = append(depMap[ent], first)
+		}
+		remains = append(remains, ent)
+	}
+
+	var levels [][]*daxSrcEntry
+	done := make(map[*daxSrcEntry]bool)
+
+	for len(remains) > 0 {
+		var level, rest []*daxSrcEntry
+
+	remainsLoop:
+		for _, ent := range remains {
+			for _, depEnt := range depMap[ent] {
+				if !done[depEnt] {
+					rest = append(rest, ent)
+					continue remainsLoop
+				}
+			}
+			level = append(level, ent)
+		}
+
+		if len(level) == 0 {
+			names := make([]string, len(rest))
+			for i, ent := range rest {
+				names[i] = ent.name
+			}
+			return nil, errs.New(DaxSrcDependenciesAreCyclic{Names: names})
+		}
+
+		for _, ent := range level {
+			done[ent] = true
+		}
+		levels = append(levels, level)
+		remains = rest
+	}
+
+	var prev *daxSrcEntry
+	for _, level := range levels {
+		for _, ent := range level {
+			ent.prev = prev
+			ent.next = nil
+			if prev == nil {
+				reg.daxSrcEntryList.head = ent
+			} else {
+				prev.next = ent
+			}
+			prev = ent
+		}
+	}
+	reg.daxSrcEntryList.last = prev
+
+	return levels, errs.Ok()
+}
+
 // Close is the function that closes and frees each resource of registered
 // global DaxSrc(s).
 // This function should always be called before an application ends.
-func Close() {
-	for ent := globalDaxSrcEntryList.head; ent != nil; ent = ent.next {
-		ent.ds.Close()
+//
+// DaxSrc(s) are closed in reverse order of their setups, so that a DaxSrc is
+// closed before DaxSrc(s) it depends on.
+//
+// If some of DaxSrc(s) implementing ErrCloser fail to close, this function
+// continues to close other DaxSrc(s) and returns an errs.Err of the reason:
+// FailToCloseGlobalDaxSrcs.
+func Close() errs.Err {
+	return defaultRegistry.Close()
+}
+
+// Close is the method that closes global DaxSrc(s) of this Registry.
+// See Close function for details.
+func (reg *Registry) Close() errs.Err {
+	return reg.closeDaxSrcs(nil)
+}
+
+func (reg *Registry) closeDaxSrcs(skipped map[*daxSrcEntry]bool) errs.Err {
+	var ag asyncGroupAsync[string]
+
+	for ent := reg.daxSrcEntryList.last; ent != nil; ent = ent.prev {
+		if skipped[ent] {
+			continue
+		}
+		err := closeWithErr(ent.daxSrc())
+		if err.IsNotOk() {
+			ag.addErr(ent.name, err)
+		}
+	}
+
+	if ag.hasErr() {
+		errMap := ag.makeErrs()
+		return errs.New(FailToCloseGlobalDaxSrcs{Errors: errMap}, causesOf(errMap)...)
 	}
+
+	return errs.Ok()
 }
 
 // StartApp is the function that calls Setup function, the argument function,
@@ -209,15 +722,33 @@ func Close() {
 // If Setup function or the argument function fails, this function stops
 // calling other functions and return an errs.Err containing the error
 // reaason.
+// If only Close function fails, this function returns an errs.Err of the
+// reason: FailToCloseGlobalDaxSrcs.
+//
+// The argument SetupOption(s) are passed to Setup function, so that this
+// function can fail fast with the error reason: SetupTimedOut when DaxSrc(s)
+// do not finish setting up before the deadline.
 //
 // This function is a macro-like function aimed at reducing the amount of
 // coding.
-func StartApp(app func() errs.Err) errs.Err {
-	err := Setup()
+func StartApp(app func() errs.Err, opts ...SetupOption) errs.Err {
+	return defaultRegistry.StartApp(app, opts...)
+}
+
+// StartApp is the method that calls Setup method, the argument function, and
+// Close method of this Registry in order.
+// See StartApp function for details.
+func (reg *Registry) StartApp(app func() errs.Err, opts ...SetupOption) (err errs.Err) {
+	err = reg.Setup(opts...)
 	if err.IsNotOk() {
 		return err
 	}
-	defer Close()
+	defer func() {
+		closeErr := reg.Close()
+		if err.IsOk() {
+			err = closeErr
+		}
+	}()
 
 	return app()
 }
@@ -236,73 +767,123 @@ type Dax interface {
 // DaxBase is the interface that declares the methods to manage DaxSrc(s).
 // And this interface declarees unexported methods to process a transaction.
 //
-// Close is the method to close and free all local DaxSrc(s).
+// Close is the method to close and free all local DaxSrc(s), and returns an
+// errs.Err of the reason: FailToCloseLocalDaxSrcs if some of them failed to
+// close.
 // Uses is the method to register and setup a local DaxSrc with an argument
 // name.
 // Uses_ is the method that creates a runner function which runs #Uses method.
 // Disuses is the method to close and remove a local DaxSrc specified by
-// an argument name.
+// an argument name, and returns an errs.Err of the reason:
+// FailToCloseLocalDaxSrc if the DaxSrc failed to close.
 // Disuses_ is the method that creates a runner function which runs #Disuses
 // method.
+// AddTxnHook is the method to register a TxnHook which is applied to
+// transactions of this DaxBase only.
+// HealthCheck and HealthCheckCtx are the methods to check the health of
+// DaxSrc(s) usable in this DaxBase.
 type DaxBase interface {
 	Dax
 
-	Close()
+	Close() errs.Err
 	Uses(name string, ds DaxSrc) errs.Err
 	Uses_(name string, ds DaxSrc) func() errs.Err
-	Disuses(name string)
+	Disuses(name string) errs.Err
 	Disuses_(name string) func() errs.Err
+	AddTxnHook(hook TxnHook)
+	HealthCheck() map[string]errs.Err
+	HealthCheckCtx(ctx context.Context) map[string]errs.Err
 
-	begin()
+	beginCtx(ctx context.Context, readOnly bool) errs.Err
 	commit() errs.Err
-	rollback()
-	end()
+	rollback() bool
+	end() errs.Err
+	isNestedTxn() bool
+	setLogicCtx(ctx context.Context) context.Context
+	daxConnNames() []string
+	eachTxnHook(fn func(hook TxnHook))
+	checkWriteAttempted() errs.Err
+	getTracer() Tracer
+	getMetricsSink() MetricsSink
 }
 
 type daxBaseImpl struct {
 	DaxBase
 
+	registry *Registry
+
 	isLocalDaxSrcsFixed  bool
 	localDaxSrcEntryList daxSrcEntryList
+	localTxnHookList     txnHookList
 
 	daxSrcEntryMap map[string]*daxSrcEntry
 	agSync         asyncGroupSync
 
-	daxConnMap   om.Map[string, DaxConn]
-	daxConnMutex sync.Mutex
+	daxConnMap     om.Map[string, DaxConn]
+	daxConnMutex   sync.Mutex
+	preparedMap    map[string]bool
+	committedMap   map[string]bool
+	daxSrcUsageMap map[string]*daxSrcUsage
+
+	ctx       context.Context
+	logicCtx  context.Context
+	nestedTxn *nestedTxn
+	readOnly  bool
 }
 
-// NewDaxBase is the function that creates a new DaxBase instance.
+// NewDaxBase is the function that creates a new DaxBase instance which uses
+// global DaxSrc(s) of the default Registry.
 func NewDaxBase() DaxBase {
-	isGlobalDaxSrcsFixed = true
-	errs.FixCfg()
+	return defaultRegistry.NewDaxBase()
+}
+
+// NewDaxBase is the method that creates a new DaxBase instance which uses
+// global DaxSrc(s) and TxnHook(s) of this Registry.
+func (reg *Registry) NewDaxBase() DaxBase {
+	reg.fix()
 
 	base := &daxBaseImpl{
+		registry:       reg,
 		daxSrcEntryMap: make(map[string]*daxSrcEntry),
 		daxConnMap:     om.New[string, DaxConn](),
+		preparedMap:    make(map[string]bool),
+		committedMap:   make(map[string]bool),
+		daxSrcUsageMap: make(map[string]*daxSrcUsage),
 	}
 
-	for ent := globalDaxSrcEntryList.last; ent != nil; ent = ent.prev {
+	for ent := reg.daxSrcEntryList.last; ent != nil; ent = ent.prev {
 		base.daxSrcEntryMap[ent.name] = ent
 	}
 
 	return base
 }
 
-func (base *daxBaseImpl) Close() {
+func (base *daxBaseImpl) Close() errs.Err {
 	if base.isLocalDaxSrcsFixed {
-		return
+		return errs.Ok()
 	}
 
+	var ag asyncGroupAsync[string]
+
 	for ent := base.localDaxSrcEntryList.head; ent != nil; ent = ent.next {
 		if !ent.deleted {
 			ent.deleted = true
-			ent.ds.Close()
+			err := closeWithErr(ent.daxSrc())
+			if err.IsNotOk() {
+				ag.addErr(ent.name, err)
+			}
 		}
 	}
 
 	base.localDaxSrcEntryList.head = nil
 	base.localDaxSrcEntryList.last = nil
+
+	if ag.hasErr() {
+		errMap := ag.makeErrs()
+		return errs.New(FailToCloseLocalDaxSrcs{Errors: errMap}, causesOf(errMap)...)
+	}
+
+	return errs.Ok()
 }
 
 func (base *daxBaseImpl) Uses(name string, ds DaxSrc) errs.Err {
@@ -341,13 +922,13 @@ func (base *daxBaseImpl) Uses_(name string, ds DaxSrc) func() errs.Err {
 	}
 }
 
-func (base *daxBaseImpl) Disuses(name string) {
+func (base *daxBaseImpl) Disuses(name string) errs.Err {
 	if base.isLocalDaxSrcsFixed {
-		return
+		return errs.Ok()
 	}
 
 	ent := base.daxSrcEntryMap[name]
-	if ent != nil && ent.local {
+	if ent != nil && ent.local && !ent.deleted {
 		ent.deleted = true
 
 		if ent.prev != nil {
@@ -362,68 +943,256 @@ func (base *daxBaseImpl) Disuses(name string) {
 			base.localDaxSrcEntryList.last = ent.prev
 		}
 
-		ent.ds.Close()
+		err := closeWithErr(ent.daxSrc())
+		if err.IsNotOk() {
+			return errs.New(FailToCloseLocalDaxSrc{Name: name}, err)
+		}
 	}
+
+	return errs.Ok()
 }
 
 func (base *daxBaseImpl) Disuses_(name string) func() errs.Err {
 	return func() errs.Err {
-		base.Disuses(name)
-		return errs.Ok()
+		return base.Disuses(name)
 	}
 }
 
-func (base *daxBaseImpl) begin() {
+func (base *daxBaseImpl) beginCtx(ctx context.Context, readOnly bool) errs.Err {
+	if base.isLocalDaxSrcsFixed {
+		return base.beginNested(readOnly)
+	}
+
+	err := base.registry.txnGate.enter()
+	if err.IsNotOk() {
+		return err
+	}
+
 	base.isLocalDaxSrcsFixed = true
+	base.ctx = ctx
+	base.readOnly = readOnly
+	return errs.Ok()
+}
+
+func (base *daxBaseImpl) context() context.Context {
+	if base.ctx == nil {
+		return context.Background()
+	}
+	return base.ctx
+}
+
+// setLogicCtx sets the context.Context carrying the span of a running logic
+// function, which spans of creating DaxConn(s) are started with, and returns
+// the previous one.
+func (base *daxBaseImpl) setLogicCtx(ctx context.Context) context.Context {
+	prev := base.logicCtx
+	base.logicCtx = ctx
+	return prev
+}
+
+func (base *daxBaseImpl) spanParentCtx() context.Context {
+	if base.logicCtx == nil {
+		return base.context()
+	}
+	return base.logicCtx
+}
+
+func (base *daxBaseImpl) prepare() errs.Err {
+	var ag asyncGroupAsync[string]
+	ag.ctx = base.context()
+
+	for ent := base.daxConnMap.Front(); ent != nil; ent = ent.Next() {
+		p, ok := unwrapDaxConn(ent.Value()).(Preparable)
+		if !ok {
+			continue
+		}
+		ag.name = ent.Key()
+		err := base.observeDaxConn(&ag, SpanPrepare, ent.Key(), p.Prepare)
+		if err.IsNotOk() {
+			ag.wait()
+			ag.addErr(ent.Key(), err)
+			base.unmarkPrepared(&ag)
+			errMap := ag.makeErrs()
+			return errs.New(FailToPrepareDaxConn{Errors: errMap}, causesOf(errMap)...)
+		}
+		base.preparedMap[ent.Key()] = true
+	}
+
+	ag.wait()
+
+	if ag.hasErr() {
+		base.unmarkPrepared(&ag)
+		errMap := ag.makeErrs()
+		return errs.New(FailToPrepareDaxConn{Errors: errMap}, causesOf(errMap)...)
+	}
+
+	return errs.Ok()
+}
+
+func (base *daxBaseImpl) unmarkPrepared(ag *asyncGroupAsync[string]) {
+	for ent := ag.errHead; ent != nil; ent = ent.next {
+		delete(base.preparedMap, ent.name)
+	}
 }
 
 func (base *daxBaseImpl) commit() errs.Err {
+	if base.nestedTxn != nil {
+		return base.releaseSavepoint()
+	}
+
+	err := base.prepare()
+	if err.IsNotOk() {
+		return err
+	}
+
 	var ag asyncGroupAsync[string]
+	ag.ctx = base.context()
 
 	for ent := base.daxConnMap.Front(); ent != nil; ent = ent.Next() {
+		if base.preparedMap[ent.Key()] {
+			continue
+		}
 		ag.name = ent.Key()
-		err := ent.Value().Commit(&ag)
+		conn := ent.Value()
+		err := base.observeDaxConn(&ag, SpanCommit, ent.Key(), conn.Commit)
 		if err.IsNotOk() {
 			ag.wait()
 			ag.addErr(ent.Key(), err)
-			return errs.New(FailToCommitDaxConn{Errors: ag.makeErrs()})
+			errMap := ag.makeErrs()
+			return errs.New(FailToCommitDaxConn{Errors: errMap}, causesOf(errMap)...)
 		}
 	}
 
 	ag.wait()
 
 	if ag.hasErr() {
-		return errs.New(FailToCommitDaxConn{Errors: ag.makeErrs()})
+		errMap := ag.makeErrs()
+		return errs.New(FailToCommitDaxConn{Errors: errMap}, causesOf(errMap)...)
+	}
+
+	var names []string
+
+	for ent := base.daxConnMap.Front(); ent != nil; ent = ent.Next() {
+		if !base.preparedMap[ent.Key()] {
+			continue
+		}
+		ag.name = ent.Key()
+		p := unwrapDaxConn(ent.Value()).(Preparable)
+		err := base.observeDaxConn(&ag, SpanCommit, ent.Key(), p.CommitPrepared)
+		if err.IsNotOk() {
+			ag.wait()
+			ag.addErr(ent.Key(), err)
+			base.markCommitted(&ag, names)
+			errMap := ag.makeErrs()
+			return errs.New(FailToCommitDaxConn{Errors: errMap}, causesOf(errMap)...)
+		}
+		names = append(names, ent.Key())
+	}
+
+	ag.wait()
+	base.markCommitted(&ag, names)
+
+	if ag.hasErr() {
+		errMap := ag.makeErrs()
+		return errs.New(FailToCommitDaxConn{Errors: errMap}, causesOf(errMap)...)
 	}
 
 	return errs.Ok()
 }
 
-func (base *daxBaseImpl) rollback() {
+// markCommitted records prepared DaxConn(s) of which CommitPrepared succeeded,
+// so that rollback method forces back them regardless of IsCommitted.
+func (base *daxBaseImpl) markCommitted(ag *asyncGroupAsync[string], names []string) {
+	for _, name := range names {
+		base.committedMap[name] = true
+	}
+	for ent := ag.errHead; ent != nil; ent = ent.next {
+		delete(base.committedMap, ent.name)
+	}
+}
+
+// rollback rollbacks DaxConn(s), or forces back them if they are already
+// committed, and returns true if some DaxConn(s) are forced back.
+func (base *daxBaseImpl) rollback() bool {
+	if base.nestedTxn != nil {
+		base.rollbackToSavepoint()
+		return false
+	}
+
 	var ag asyncGroupAsync[string]
+	ag.ctx = uncanceledCtx{base.context()}
+	forceBacked := false
 
 	for ent := base.daxConnMap.Front(); ent != nil; ent = ent.Next() {
 		conn := ent.Value()
-		if conn.IsCommitted() {
-			conn.ForceBack(&ag)
+		if conn.IsCommitted() || base.committedMap[ent.Key()] {
+			forceBacked = true
+			base.observeDaxConn(&ag, SpanForceBack, ent.Key(), func(ag AsyncGroup) errs.Err {
+				conn.ForceBack(ag)
+				return errs.Ok()
+			})
+		} else if base.preparedMap[ent.Key()] {
+			base.observeDaxConn(&ag, SpanRollback, ent.Key(), func(ag AsyncGroup) errs.Err {
+				unwrapDaxConn(conn).(Preparable).RollbackPrepared(ag)
+				return errs.Ok()
+			})
 		} else {
-			conn.Rollback(&ag)
+			base.observeDaxConn(&ag, SpanRollback, ent.Key(), func(ag AsyncGroup) errs.Err {
+				conn.Rollback(ag)
+				return errs.Ok()
+			})
 		}
 	}
 
 	ag.wait()
+	return forceBacked
 }
 
-func (base *daxBaseImpl) end() {
+func (base *daxBaseImpl) end() errs.Err {
+	if base.nestedTxn != nil {
+		base.endNested()
+		return errs.Ok()
+	}
+
+	var ag asyncGroupAsync[string]
+
 	for {
 		ent := base.daxConnMap.FrontAndLdelete()
 		if ent == nil {
 			break
 		}
-		ent.Value().Close()
+		err := closeWithErr(ent.Value())
+		if err.IsNotOk() {
+			ag.addErr(ent.Key(), err)
+		}
+		if usage, exists := base.daxSrcUsageMap[ent.Key()]; exists {
+			delete(base.daxSrcUsageMap, ent.Key())
+			usage.release()
+		}
+	}
+
+	for name := range base.preparedMap {
+		delete(base.preparedMap, name)
+	}
+	for name := range base.committedMap {
+		delete(base.committedMap, name)
+	}
+
+	if base.isLocalDaxSrcsFixed {
+		base.registry.txnGate.exit()
 	}
 
 	base.isLocalDaxSrcsFixed = false
+	base.ctx = nil
+	base.logicCtx = nil
+	base.readOnly = false
+
+	if ag.hasErr() {
+		errMap := ag.makeErrs()
+		return errs.New(FailToCloseDaxConns{Errors: errMap}, causesOf(errMap)...)
+	}
+
+	return errs.Ok()
 }
 
 func (base *daxBaseImpl) getDaxConn(name string) (DaxConn, errs.Err) {
@@ -442,7 +1211,7 @@ func (base *daxBaseImpl) getDaxConn(name string) (DaxConn, errs.Err) {
 		}
 
 		if ent.deleted && ent.local {
-			for gEnt := globalDaxSrcEntryList.head; gEnt != nil; gEnt = gEnt.next {
+			for gEnt := base.registry.daxSrcEntryList.head; gEnt != nil; gEnt = gEnt.next {
 				if gEnt.name == name {
 					base.daxSrcEntryMap[ent.name] = gEnt
 					ent = gEnt
@@ -454,13 +1223,20 @@ func (base *daxBaseImpl) getDaxConn(name string) (DaxConn, errs.Err) {
 			}
 		}
 
-		conn, err := ent.ds.CreateDaxConn()
+		ds, usage := ent.acquire()
+
+		_, span := base.registry.tracer.Start(base.spanParentCtx(), SpanCreateDaxConn)
+		span.SetAttr(AttrDaxSrcName, name)
+		conn, err := base.createDaxConn(name, ds)
+		span.End(err)
 		if err.IsNotOk() {
-			return nil, errs.New(FailToCreateDaxConn{Name: name}, err)
-		}
-		if conn == nil {
-			return nil, errs.New(CreatedDaxConnIsNil{Name: name})
+			base.registry.metrics.IncCounter(MetricCreateDaxConnFailures,
+				map[string]string{LabelDaxSrc: name})
+			usage.release()
+			return nil, err
 		}
+
+		base.daxSrcUsageMap[name] = usage
 		return conn, nil
 	})
 
@@ -470,6 +1246,49 @@ func (base *daxBaseImpl) getDaxConn(name string) (DaxConn, errs.Err) {
 	return conn, errs.Ok()
 }
 
+func (base *daxBaseImpl) createDaxConn(name string, ds DaxSrc) (DaxConn, errs.Err) {
+	var conn DaxConn
+	var err errs.Err
+	if cds, ok := ds.(CtxDaxSrc); ok {
+		conn, err = cds.CreateDaxConnCtx(base.context())
+	} else {
+		conn, err = ds.CreateDaxConn()
+	}
+	if err.IsNotOk() {
+		return nil, errs.New(FailToCreateDaxConn{Name: name}, err)
+	}
+	if conn == nil {
+		return nil, errs.New(CreatedDaxConnIsNil{Name: name})
+	}
+	if base.readOnly {
+		if roc, ok := unwrapDaxConn(conn).(ReadOnlyDaxConn); ok {
+			roc.SetReadOnly()
+		}
+	}
+	err = base.savepointNewDaxConn(name, conn)
+	if err.IsNotOk() {
+		closeWithErr(conn)
+		return nil, err
+	}
+	return conn, errs.Ok()
+}
+
+// daxConnWrapper is the interface for a DaxConn which is created by a DaxSrc
+// wrapping another DaxConn, like DaxConn(s) created by PooledDaxSrc.
+type daxConnWrapper interface {
+	unwrapDaxConn() DaxConn
+}
+
+func unwrapDaxConn(conn DaxConn) DaxConn {
+	for {
+		w, ok := conn.(daxConnWrapper)
+		if !ok {
+			return conn
+		}
+		conn = w.unwrapDaxConn()
+	}
+}
+
 // GetDaxConn is the function to cast type of DaxConn instance.
 // If the cast failed, this function returns an errs.Err of the reason:
 // FailToCastDaxConn with the DaxConn name and type names of source and
@@ -477,12 +1296,12 @@ func (base *daxBaseImpl) getDaxConn(name string) (DaxConn, errs.Err) {
 func GetDaxConn[C DaxConn](dax Dax, name string) (C, errs.Err) {
 	conn, err := dax.getDaxConn(name)
 	if err.IsOk() {
-		casted, ok := conn.(C)
+		casted, ok := unwrapDaxConn(conn).(C)
 		if ok {
 			return casted, err
 		}
 
-		from := typeNameOf(conn)
+		from := typeNameOf(unwrapDaxConn(conn))
 		to := typeNameOfTypeParam[C]()
 		err = errs.New(FailToCastDaxConn{Name: name, FromType: from, ToType: to})
 	}
@@ -501,10 +1320,52 @@ func GetDaxConn[C DaxConn](dax Dax, name string) (C, errs.Err) {
 // If there are commit errors after some DaxConn(s) are commited, or there are
 // DaxConn(s) which don't have rollback mechanism, this function executes
 // ForceBack methods of those DaxConn(s).
+// If some DaxConn(s) implement Preparable, this function prepares them before
+// commiting any DaxConn, as described in the document of Preparable.
 // And after that, this function ends the transaction.
+// At some points of this lifecycle, functions of registered TxnHook(s) are
+// called.
+//
+// If this function is called in a logic function of another transaction on
+// the same DaxBase, it runs as a nested transaction with savepoints, as
+// described in the document of Savepointer.
 //
 // During a transaction, it is denied to add or remove any local DaxSrc(s).
 func Txn[D any](base DaxBase, logics ...func(dax D) errs.Err) errs.Err {
+	return TxnCtx[D](context.Background(), base, logics...)
+}
+
+// Txn_ is the function that creates a runner function which runs a Txn
+// function.
+func Txn_[D any](base DaxBase, logics ...func(dax D) errs.Err) func() errs.Err {
+	return func() errs.Err {
+		return Txn[D](base, logics...)
+	}
+}
+
+// TxnCtx is the function that executes logic functions in a transaction with
+// a context.Context.
+//
+// This function works as same as Txn function, but the argument
+// context.Context is passed to DaxSrc(s) implementing CtxDaxSrc when creating
+// DaxConn(s), and to DaxConn#Commit, DaxConn#Rollback and DaxConn#ForceBack
+// through the AsyncGroup.
+// If the context.Context is canceled or its deadline is exceeded before
+// commiting, this function stops executing logic functions, rollbacks all
+// updates, and returns an errs.Err of the reason: TxnIsCanceled.
+func TxnCtx[D any](ctx context.Context, base DaxBase, logics ...func(dax D) errs.Err) errs.Err {
+	return runTxn[D](ctx, base, false, logics)
+}
+
+// TxnCtx_ is the function that creates a runner function which runs a TxnCtx
+// function.
+func TxnCtx_[D any](ctx context.Context, base DaxBase, logics ...func(dax D) errs.Err) func() errs.Err {
+	return func() errs.Err {
+		return TxnCtx[D](ctx, base, logics...)
+	}
+}
+
+func runTxn[D any](ctx context.Context, base DaxBase, readOnly bool, logics []func(dax D) errs.Err) errs.Err {
 	dax, ok := base.(D)
 	if !ok {
 		from := typeNameOf(&base)[1:]
@@ -512,33 +1373,108 @@ func Txn[D any](base DaxBase, logics ...func(dax D) errs.Err) errs.Err {
 		return errs.New(FailToCastDaxBase{FromType: from, ToType: to})
 	}
 
-	base.begin()
-	defer base.end()
+	tracer := base.getTracer()
+	ctx, txnSpan := tracer.Start(ctx, SpanTxn)
+	txnSpan.SetAttr(AttrReadOnly, readOnly)
 
-	err := errs.Ok()
+	runBeforeBegin(base)
+	err := base.beginCtx(ctx, readOnly)
+	nested := base.isNestedTxn()
 
-	for _, logic := range logics {
+	defer func() {
+		names := base.daxConnNames()
+		closeErr := base.end()
+		if closeErr.IsNotOk() {
+			runOnCloseErr(base, closeErr)
+		}
+		runAfterEnd(base, names, err)
+		txnSpan.End(err)
+	}()
+
+	if err.IsNotOk() {
+		return err
+	}
+
+	for i, logic := range logics {
+		err = checkCtx(ctx)
+		if err.IsNotOk() {
+			break
+		}
+		logicCtx, logicSpan := tracer.Start(ctx, SpanLogic)
+		logicSpan.SetAttr(AttrLogicIndex, i)
+		prevCtx := base.setLogicCtx(logicCtx)
+		runBeforeLogic(base, i)
 		err = logic(dax)
+		runAfterLogic(base, i, err)
+		base.setLogicCtx(prevCtx)
+		logicSpan.End(err)
 		if err.IsNotOk() {
 			break
 		}
 	}
 
+	if err.IsOk() {
+		err = checkCtx(ctx)
+	}
+
+	if readOnly {
+		if err.IsOk() {
+			err = base.checkWriteAttempted()
+		}
+		forceBacked := base.rollback()
+		if err.IsNotOk() && !nested {
+			runAfterRollback(base, base.daxConnNames(), err)
+		}
+		if !nested {
+			recordTxnOutcome(base, readOnly, err, forceBacked)
+		}
+		return err
+	}
+
 	if err.IsOk() {
 		err = base.commit()
+		if err.IsOk() && !nested {
+			runAfterCommit(base, base.daxConnNames())
+		}
 	}
 
+	forceBacked := false
 	if err.IsNotOk() {
-		base.rollback()
+		forceBacked = base.rollback()
+		if !nested {
+			runAfterRollback(base, base.daxConnNames(), err)
+		}
+	}
+	if !nested {
+		recordTxnOutcome(base, readOnly, err, forceBacked)
 	}
 
 	return err
 }
 
-// Txn_ is the function that creates a runner function which runs a Txn
-// function.
-func Txn_[D any](base DaxBase, logics ...func(dax D) errs.Err) func() errs.Err {
-	return func() errs.Err {
-		return Txn[D](base, logics...)
+func checkCtx(ctx context.Context) errs.Err {
+	e := ctx.Err()
+	if e != nil {
+		return errs.New(TxnIsCanceled{}, e)
 	}
+	return errs.Ok()
+}
+
+// uncanceledCtx is a context.Context which keeps values of the wrapped
+// context.Context but is never canceled, and is used to rollback updates
+// after the transaction's context.Context is canceled.
+type uncanceledCtx struct {
+	context.Context
+}
+
+func (uncanceledCtx) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (uncanceledCtx) Done() <-chan struct{} {
+	return nil
+}
+
+func (uncanceledCtx) Err() error {
+	return nil
 }
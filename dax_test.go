@@ -2,7 +2,11 @@ package sabi
 
 import (
 	"container/list"
+	"context"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -20,12 +24,9 @@ var (
 )
 
 func Reset() {
+	defaultRegistry.Reset()
 	errs.FixCfg()
 
-	isGlobalDaxSrcsFixed = false
-	globalDaxSrcEntryList.head = nil
-	globalDaxSrcEntryList.last = nil
-
 	WillFailToSetupFooDaxSrc = false
 	WillFailToSetupBarDaxSrc = false
 
@@ -36,6 +37,7 @@ func Reset() {
 	WillFailToCommitBarDaxConn = false
 
 	Logs.Init()
+	DepLogs.Init()
 }
 
 type (
@@ -162,7 +164,7 @@ func TestUses_ok(t *testing.T) {
 
 	Uses("cliargs", FooDaxSrc{})
 
-	ent0 := globalDaxSrcEntryList.head
+	ent0 := defaultRegistry.daxSrcEntryList.head
 	assert.Equal(t, ent0.name, "cliargs")
 	assert.IsType(t, ent0.ds, FooDaxSrc{})
 	assert.False(t, ent0.local)
@@ -171,7 +173,7 @@ func TestUses_ok(t *testing.T) {
 
 	Uses("database", &FooDaxSrc{})
 
-	ent0 = globalDaxSrcEntryList.head
+	ent0 = defaultRegistry.daxSrcEntryList.head
 	assert.Equal(t, ent0.name, "cliargs")
 	assert.IsType(t, ent0.ds, FooDaxSrc{})
 	assert.False(t, ent0.local)
@@ -186,7 +188,7 @@ func TestUses_ok(t *testing.T) {
 
 	Uses("file", &BarDaxSrc{})
 
-	ent0 = globalDaxSrcEntryList.head
+	ent0 = defaultRegistry.daxSrcEntryList.head
 	assert.Equal(t, ent0.name, "cliargs")
 	assert.IsType(t, ent0.ds, FooDaxSrc{})
 	assert.False(t, ent0.local)
@@ -211,7 +213,7 @@ func TestUses_nameAlreadyExists(t *testing.T) {
 
 	Uses("database", FooDaxSrc{})
 
-	ent0 := globalDaxSrcEntryList.head
+	ent0 := defaultRegistry.daxSrcEntryList.head
 	assert.Equal(t, ent0.name, "database")
 	assert.IsType(t, ent0.ds, FooDaxSrc{})
 	assert.False(t, ent0.local)
@@ -220,7 +222,7 @@ func TestUses_nameAlreadyExists(t *testing.T) {
 
 	Uses("database", &FooDaxSrc{})
 
-	ent0 = globalDaxSrcEntryList.head
+	ent0 = defaultRegistry.daxSrcEntryList.head
 	assert.Equal(t, ent0.name, "database")
 	assert.IsType(t, ent0.ds, FooDaxSrc{})
 	assert.False(t, ent0.local)
@@ -238,14 +240,14 @@ func TestSetup_zeroDs(t *testing.T) {
 	Reset()
 	defer Reset()
 
-	assert.False(t, isGlobalDaxSrcsFixed)
+	assert.False(t, defaultRegistry.isDaxSrcsFixed)
 	assert.Nil(t, Logs.Front())
 
 	err := Setup()
 	assert.True(t, err.IsOk())
 	defer Close()
 
-	assert.True(t, isGlobalDaxSrcsFixed)
+	assert.True(t, defaultRegistry.isDaxSrcsFixed)
 	assert.Nil(t, Logs.Front())
 }
 
@@ -255,14 +257,14 @@ func TestSetup_oneDs(t *testing.T) {
 
 	Uses("cliargs", FooDaxSrc{})
 
-	assert.False(t, isGlobalDaxSrcsFixed)
+	assert.False(t, defaultRegistry.isDaxSrcsFixed)
 	assert.Nil(t, Logs.Front())
 
 	err := Setup()
 	assert.True(t, err.IsOk())
 	defer Close()
 
-	assert.True(t, isGlobalDaxSrcsFixed)
+	assert.True(t, defaultRegistry.isDaxSrcsFixed)
 	log := Logs.Front()
 	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
 	log = log.Next()
@@ -276,14 +278,14 @@ func TestSetup_multipleDs(t *testing.T) {
 	Uses("cliargs", FooDaxSrc{})
 	Uses("database", &BarDaxSrc{})
 
-	assert.False(t, isGlobalDaxSrcsFixed)
+	assert.False(t, defaultRegistry.isDaxSrcsFixed)
 	assert.Nil(t, Logs.Front())
 
 	err := Setup()
 	assert.True(t, err.IsOk())
 	defer Close()
 
-	assert.True(t, isGlobalDaxSrcsFixed)
+	assert.True(t, defaultRegistry.isDaxSrcsFixed)
 	log := Logs.Front()
 	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
 	log = log.Next()
@@ -298,31 +300,31 @@ func TestSetup_cannotAddAfterSetup(t *testing.T) {
 
 	Uses("cliargs", FooDaxSrc{})
 
-	assert.False(t, isGlobalDaxSrcsFixed)
+	assert.False(t, defaultRegistry.isDaxSrcsFixed)
 	assert.Nil(t, Logs.Front())
 
 	err := Setup()
 	assert.True(t, err.IsOk())
 
-	assert.True(t, isGlobalDaxSrcsFixed)
+	assert.True(t, defaultRegistry.isDaxSrcsFixed)
 	log := Logs.Front()
 	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
 	log = log.Next()
 	assert.Nil(t, log)
 
-	ent := globalDaxSrcEntryList.head
+	ent := defaultRegistry.daxSrcEntryList.head
 	assert.IsType(t, ent.ds, FooDaxSrc{})
 	assert.Nil(t, ent.next)
 
 	Uses("database", &FooDaxSrc{})
 
-	assert.True(t, isGlobalDaxSrcsFixed)
+	assert.True(t, defaultRegistry.isDaxSrcsFixed)
 	log = Logs.Front()
 	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
 	log = log.Next()
 	assert.Nil(t, log)
 
-	ent = globalDaxSrcEntryList.head
+	ent = defaultRegistry.daxSrcEntryList.head
 	assert.IsType(t, ent.ds, FooDaxSrc{})
 	assert.Nil(t, ent.next)
 }
@@ -342,7 +344,7 @@ func TestSetup_error_sync(t *testing.T) {
 	assert.Equal(t, len(errmap), 1)
 	assert.IsType(t, errmap["cliargs"].Reason(), FailToSetupFooDaxSrc{})
 
-	assert.True(t, isGlobalDaxSrcsFixed)
+	assert.True(t, defaultRegistry.isDaxSrcsFixed)
 
 	log := Logs.Front()
 	assert.Equal(t, log.Value, "FooDaxSrc#Close")
@@ -365,7 +367,7 @@ func TestSetup_error_async(t *testing.T) {
 	assert.Equal(t, len(errmap), 1)
 	assert.IsType(t, errmap["cliargs"].Reason(), FailToSetupBarDaxSrc{})
 
-	assert.True(t, isGlobalDaxSrcsFixed)
+	assert.True(t, defaultRegistry.isDaxSrcsFixed)
 	log := Logs.Front()
 	assert.Equal(t, log.Value, "BarDaxSrc#Close")
 	log = log.Next()
@@ -390,12 +392,12 @@ func TestSetup_error_asyncAndSync(t *testing.T) {
 	assert.IsType(t, errmap["cliargs"].Reason(), FailToSetupBarDaxSrc{})
 	assert.IsType(t, errmap["database"].Reason(), FailToSetupFooDaxSrc{})
 
-	assert.True(t, isGlobalDaxSrcsFixed)
+	assert.True(t, defaultRegistry.isDaxSrcsFixed)
 	log := Logs.Front()
-	assert.Equal(t, log.Value, "BarDaxSrc#Close")
-	log = log.Next()
 	assert.Equal(t, log.Value, "FooDaxSrc#Close")
 	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxSrc#Close")
+	log = log.Next()
 	assert.Nil(t, log)
 }
 
@@ -477,12 +479,12 @@ func TestNewDaxBase_withNoGlobalDs(t *testing.T) {
 	Reset()
 	defer Reset()
 
-	assert.False(t, isGlobalDaxSrcsFixed)
+	assert.False(t, defaultRegistry.isDaxSrcsFixed)
 
 	base := NewDaxBase().(*daxBaseImpl)
 	defer base.Close()
 
-	assert.True(t, isGlobalDaxSrcsFixed)
+	assert.True(t, defaultRegistry.isDaxSrcsFixed)
 
 	assert.False(t, base.isLocalDaxSrcsFixed)
 	assert.Nil(t, base.localDaxSrcEntryList.head)
@@ -498,7 +500,7 @@ func TestNewDaxBase_withSomeGlobalDs(t *testing.T) {
 	Reset()
 	defer Reset()
 
-	assert.False(t, isGlobalDaxSrcsFixed)
+	assert.False(t, defaultRegistry.isDaxSrcsFixed)
 
 	Uses("cliargs", FooDaxSrc{})
 	Uses("database", &BarDaxSrc{})
@@ -507,7 +509,7 @@ func TestNewDaxBase_withSomeGlobalDs(t *testing.T) {
 		base := NewDaxBase().(*daxBaseImpl)
 		defer base.Close()
 
-		assert.True(t, isGlobalDaxSrcsFixed)
+		assert.True(t, defaultRegistry.isDaxSrcsFixed)
 
 		assert.False(t, base.isLocalDaxSrcsFixed)
 		assert.Nil(t, base.localDaxSrcEntryList.head)
@@ -593,7 +595,7 @@ func TestDax_Uses_doNothingWhileFixed(t *testing.T) {
 		assert.Equal(t, len(base.daxSrcEntryMap), 0)
 		assert.Equal(t, base.daxConnMap.Len(), 0)
 
-		base.begin()
+		base.beginCtx(context.Background(), false)
 
 		err := base.Uses("cliargs", FooDaxSrc{})
 		assert.True(t, err.IsOk())
@@ -884,7 +886,7 @@ func TestDax_Disuses_doNothingWhileFixed(t *testing.T) {
 		assert.Nil(t, ent)
 
 		assert.False(t, base.isLocalDaxSrcsFixed)
-		base.begin()
+		base.beginCtx(context.Background(), false)
 		assert.True(t, base.isLocalDaxSrcsFixed)
 
 		base.Disuses("cliargs")
@@ -1064,7 +1066,7 @@ func TestDax_Close_doNothingWhileFixed(t *testing.T) {
 		assert.Nil(t, ent)
 
 		assert.False(t, base.isLocalDaxSrcsFixed)
-		base.begin()
+		base.beginCtx(context.Background(), false)
 		assert.True(t, base.isLocalDaxSrcsFixed)
 
 		base.Close()
@@ -1104,7 +1106,7 @@ func TestGetDaxConn_ok(t *testing.T) {
 	err = base.Uses("file", &FooDaxSrc{})
 	assert.True(t, err.IsOk())
 
-	base.begin()
+	base.beginCtx(context.Background(), false)
 	defer base.end()
 
 	conn1, err := GetDaxConn[FooDaxConn](base, "cliargs")
@@ -1130,7 +1132,7 @@ func TestGetDaxConn_daxConnIsAlreadyCreated(t *testing.T) {
 	err := base.Uses("cliargs", FooDaxSrc{})
 	assert.True(t, err.IsOk())
 
-	base.begin()
+	base.beginCtx(context.Background(), false)
 	defer base.end()
 
 	conn1, err := GetDaxConn[FooDaxConn](base, "cliargs")
@@ -1150,7 +1152,7 @@ func TestGetDaxConn_daxSrcIsNotFound(t *testing.T) {
 	base := NewDaxBase().(*daxBaseImpl)
 	defer base.Close()
 
-	base.begin()
+	base.beginCtx(context.Background(), false)
 	defer base.end()
 
 	_, err := GetDaxConn[FooDaxConn](base, "cliargs")
@@ -1173,7 +1175,7 @@ func TestGetDaxConn_daxSrcIsDisused(t *testing.T) {
 	assert.True(t, err.IsOk())
 
 	func() {
-		base.begin()
+		base.beginCtx(context.Background(), false)
 		defer base.end()
 
 		conn, err := GetDaxConn[FooDaxConn](base, "cliargs")
@@ -1184,7 +1186,7 @@ func TestGetDaxConn_daxSrcIsDisused(t *testing.T) {
 	base.Disuses("cliargs")
 
 	func() {
-		base.begin()
+		base.beginCtx(context.Background(), false)
 		defer base.end()
 
 		_, err := GetDaxConn[FooDaxConn](base, "cliargs")
@@ -1210,7 +1212,7 @@ func TestGetDaxConn_localDaxSrcIsDisusedButGlobalDaxSrcExists(t *testing.T) {
 	assert.True(t, err.IsOk())
 
 	func() {
-		base.begin()
+		base.beginCtx(context.Background(), false)
 		defer base.end()
 
 		conn, err := GetDaxConn[FooDaxConn](base, "database")
@@ -1221,7 +1223,7 @@ func TestGetDaxConn_localDaxSrcIsDisusedButGlobalDaxSrcExists(t *testing.T) {
 	base.Disuses("database")
 
 	func() {
-		base.begin()
+		base.beginCtx(context.Background(), false)
 		defer base.end()
 
 		conn, err := GetDaxConn[*BarDaxConn](base, "database")
@@ -1243,7 +1245,7 @@ func TestGetDaxConn_failToCreateDaxConn(t *testing.T) {
 	WillFailToCreateFooDaxConn = true
 
 	func() {
-		base.begin()
+		base.beginCtx(context.Background(), false)
 		defer base.end()
 
 		_, err := GetDaxConn[FooDaxConn](base, "database")
@@ -1270,7 +1272,7 @@ func TestGetDaxConn_createdDaxConnIsNil(t *testing.T) {
 	WillCreatedFooDaxConnBeNil = true
 
 	func() {
-		base.begin()
+		base.beginCtx(context.Background(), false)
 		defer base.end()
 
 		_, err := GetDaxConn[FooDaxConn](base, "database")
@@ -1294,7 +1296,7 @@ func TestGetDaxConn_failToCastDaxConn(t *testing.T) {
 	assert.True(t, err.IsOk())
 
 	func() {
-		base.begin()
+		base.beginCtx(context.Background(), false)
 		defer base.end()
 
 		_, err := GetDaxConn[*BarDaxConn](base, "database")
@@ -1653,3 +1655,1197 @@ func TestTxn_runner(t *testing.T) {
 	log = log.Next()
 	assert.Nil(t, log)
 }
+
+type ctxKey struct{}
+
+type BazDaxSrc struct{}
+
+func (ds BazDaxSrc) Setup(ag AsyncGroup) errs.Err {
+	Logs.PushBack("BazDaxSrc#Setup " + fmtCtxValue(ContextOf(ag)))
+	return errs.Ok()
+}
+func (ds BazDaxSrc) Close() {
+	Logs.PushBack("BazDaxSrc#Close")
+}
+func (ds BazDaxSrc) CreateDaxConn() (DaxConn, errs.Err) {
+	Logs.PushBack("BazDaxSrc#CreateDaxConn")
+	return &BazDaxConn{ctx: context.Background()}, errs.Ok()
+}
+func (ds BazDaxSrc) CreateDaxConnCtx(ctx context.Context) (DaxConn, errs.Err) {
+	Logs.PushBack("BazDaxSrc#CreateDaxConnCtx " + fmtCtxValue(ctx))
+	return &BazDaxConn{ctx: ctx}, errs.Ok()
+}
+
+type BazDaxConn struct {
+	ctx       context.Context
+	committed bool
+}
+
+func (conn *BazDaxConn) Commit(ag AsyncGroup) errs.Err {
+	Logs.PushBack("BazDaxConn#Commit " + fmtCtxValue(ContextOf(ag)))
+	conn.committed = true
+	return errs.Ok()
+}
+func (conn *BazDaxConn) IsCommitted() bool {
+	return conn.committed
+}
+func (conn *BazDaxConn) Rollback(ag AsyncGroup) {
+	ctx := ContextOf(ag)
+	Logs.PushBack("BazDaxConn#Rollback " + fmtCtxValue(ctx) + " " + fmt.Sprintf("%v", ctx.Err()))
+}
+func (conn *BazDaxConn) ForceBack(ag AsyncGroup) {
+	Logs.PushBack("BazDaxConn#ForceBack " + fmtCtxValue(ContextOf(ag)))
+}
+func (conn *BazDaxConn) Close() {
+	Logs.PushBack("BazDaxConn#Close")
+}
+
+func fmtCtxValue(ctx context.Context) string {
+	return fmt.Sprintf("%v", ctx.Value(ctxKey{}))
+}
+
+func TestSetupCtx_ok(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("baz", BazDaxSrc{})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "setup")
+	err := SetupCtx(ctx)
+	assert.True(t, err.IsOk())
+	Close()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "BazDaxSrc#Setup setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxnCtx_ok(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	func() {
+		base := NewDaxBase()
+		defer base.Close()
+
+		err := base.Uses("database", BazDaxSrc{})
+		assert.True(t, err.IsOk())
+
+		ctx := context.WithValue(context.Background(), ctxKey{}, "txn")
+
+		err = TxnCtx(ctx, base, func(dax any) errs.Err {
+			conn, err := GetDaxConn[*BazDaxConn](dax.(Dax), "database")
+			assert.True(t, err.IsOk())
+			assert.Equal(t, conn.ctx, ctx)
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+	}()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "BazDaxSrc#Setup <nil>")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxSrc#CreateDaxConnCtx txn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxConn#Commit txn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxnCtx_canceledBeforeTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	func() {
+		base := NewDaxBase()
+		defer base.Close()
+
+		err := base.Uses("database", BazDaxSrc{})
+		assert.True(t, err.IsOk())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err = TxnCtx(ctx, base, func(dax any) errs.Err {
+			Logs.PushBack("run logic")
+			return errs.Ok()
+		})
+		switch err.Reason().(type) {
+		case TxnIsCanceled:
+			assert.Equal(t, err.Cause(), context.Canceled)
+		default:
+			assert.Fail(t, err.Error())
+		}
+	}()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "BazDaxSrc#Setup <nil>")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxnCtx_canceledDuringTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	func() {
+		base := NewDaxBase()
+		defer base.Close()
+
+		err := base.Uses("database", BazDaxSrc{})
+		assert.True(t, err.IsOk())
+
+		ctx, cancel := context.WithCancel(
+			context.WithValue(context.Background(), ctxKey{}, "txn"))
+		defer cancel()
+
+		err = TxnCtx(ctx, base, func(dax any) errs.Err {
+			_, err := GetDaxConn[*BazDaxConn](dax.(Dax), "database")
+			assert.True(t, err.IsOk())
+			Logs.PushBack("run logic 1")
+			cancel()
+			return errs.Ok()
+		}, func(dax any) errs.Err {
+			Logs.PushBack("run logic 2")
+			return errs.Ok()
+		})
+		switch err.Reason().(type) {
+		case TxnIsCanceled:
+			assert.Equal(t, err.Cause(), context.Canceled)
+		default:
+			assert.Fail(t, err.Error())
+		}
+	}()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "BazDaxSrc#Setup <nil>")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxSrc#CreateDaxConnCtx txn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "run logic 1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxConn#Rollback txn <nil>")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BazDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxnCtx_deadlineExceeded(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	err := TxnCtx(ctx, base, func(dax any) errs.Err {
+		time.Sleep(10 * time.Millisecond)
+		return errs.Ok()
+	})
+	switch err.Reason().(type) {
+	case TxnIsCanceled:
+		assert.Equal(t, err.Cause(), context.DeadlineExceeded)
+	default:
+		assert.Fail(t, err.Error())
+	}
+}
+
+func TestTxnCtx_runner(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := TxnCtx_(ctx, base, func(dax any) errs.Err {
+		return errs.Ok()
+	})()
+	assert.IsType(t, err.Reason(), TxnIsCanceled{})
+}
+
+var (
+	WillFailToPrepareQuxDaxConn map[string]bool
+	WillFailToCommitQuxDaxConn  map[string]bool
+	WillPrepareQuxDaxConnAsync  bool
+)
+
+type (
+	FailToPrepareQuxDaxConn struct{}
+	FailToCommitQuxDaxConn  struct{}
+)
+
+type QuxDaxSrc struct {
+	name string
+}
+
+func (ds QuxDaxSrc) Setup(ag AsyncGroup) errs.Err {
+	return errs.Ok()
+}
+func (ds QuxDaxSrc) Close() {}
+func (ds QuxDaxSrc) CreateDaxConn() (DaxConn, errs.Err) {
+	return &QuxDaxConn{name: ds.name}, errs.Ok()
+}
+
+type QuxDaxConn struct {
+	name      string
+	committed bool
+}
+
+func (conn *QuxDaxConn) Prepare(ag AsyncGroup) errs.Err {
+	prepare := func() errs.Err {
+		if WillFailToPrepareQuxDaxConn[conn.name] {
+			return errs.New(FailToPrepareQuxDaxConn{})
+		}
+		Logs.PushBack(conn.name + "#Prepare")
+		return errs.Ok()
+	}
+	if WillPrepareQuxDaxConnAsync {
+		ag.Add(prepare)
+		return errs.Ok()
+	}
+	return prepare()
+}
+func (conn *QuxDaxConn) CommitPrepared(ag AsyncGroup) errs.Err {
+	if WillFailToCommitQuxDaxConn[conn.name] {
+		return errs.New(FailToCommitQuxDaxConn{})
+	}
+	// This does not set committed flag, to check that DaxBase forces back
+	// committed DaxConn(s) by itself.
+	Logs.PushBack(conn.name + "#CommitPrepared")
+	return errs.Ok()
+}
+func (conn *QuxDaxConn) RollbackPrepared(ag AsyncGroup) {
+	Logs.PushBack(conn.name + "#RollbackPrepared")
+}
+func (conn *QuxDaxConn) Commit(ag AsyncGroup) errs.Err {
+	Logs.PushBack(conn.name + "#Commit")
+	conn.committed = true
+	return errs.Ok()
+}
+func (conn *QuxDaxConn) IsCommitted() bool {
+	return conn.committed
+}
+func (conn *QuxDaxConn) Rollback(ag AsyncGroup) {
+	Logs.PushBack(conn.name + "#Rollback")
+}
+func (conn *QuxDaxConn) ForceBack(ag AsyncGroup) {
+	Logs.PushBack(conn.name + "#ForceBack")
+}
+func (conn *QuxDaxConn) Close() {}
+
+func resetQux() {
+	WillFailToPrepareQuxDaxConn = make(map[string]bool)
+	WillFailToCommitQuxDaxConn = make(map[string]bool)
+	WillPrepareQuxDaxConnAsync = false
+}
+
+func runTwoPhaseTxn(t *testing.T) errs.Err {
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("qux1", QuxDaxSrc{name: "qux1"}).
+		IfOk(base.Uses_("foo", FooDaxSrc{})).
+		IfOk(base.Uses_("qux2", QuxDaxSrc{name: "qux2"}))
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	return Txn(base, func(dax any) errs.Err {
+		_, err := GetDaxConn[*QuxDaxConn](dax.(Dax), "qux1")
+		assert.True(t, err.IsOk())
+		_, err = GetDaxConn[FooDaxConn](dax.(Dax), "foo")
+		assert.True(t, err.IsOk())
+		_, err = GetDaxConn[*QuxDaxConn](dax.(Dax), "qux2")
+		assert.True(t, err.IsOk())
+		Logs.Init()
+		return errs.Ok()
+	})
+}
+
+func TestTxn_twoPhaseCommit_ok(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetQux()
+	defer resetQux()
+
+	err := runTwoPhaseTxn(t)
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "qux1#Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux1#CommitPrepared")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#CommitPrepared")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_twoPhaseCommit_failToPrepare_sync(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetQux()
+	defer resetQux()
+
+	WillFailToPrepareQuxDaxConn["qux2"] = true
+
+	err := runTwoPhaseTxn(t)
+	switch r := err.Reason().(type) {
+	case FailToPrepareDaxConn:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["qux2"].Reason(), FailToPrepareQuxDaxConn{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "qux1#Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux1#RollbackPrepared")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_twoPhaseCommit_failToPrepare_async(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetQux()
+	defer resetQux()
+
+	WillPrepareQuxDaxConnAsync = true
+	WillFailToPrepareQuxDaxConn["qux1"] = true
+	WillFailToPrepareQuxDaxConn["qux2"] = true
+
+	err := runTwoPhaseTxn(t)
+	switch r := err.Reason().(type) {
+	case FailToPrepareDaxConn:
+		assert.Equal(t, len(r.Errors), 2)
+		assert.IsType(t, r.Errors["qux1"].Reason(), FailToPrepareQuxDaxConn{})
+		assert.IsType(t, r.Errors["qux2"].Reason(), FailToPrepareQuxDaxConn{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "qux1#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_twoPhaseCommit_failToCommitNonPreparable(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetQux()
+	defer resetQux()
+
+	WillFailToCommitFooDaxConn = true
+
+	err := runTwoPhaseTxn(t)
+	switch r := err.Reason().(type) {
+	case FailToCommitDaxConn:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["foo"].Reason(), FailToCommitFooDaxConn{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "qux1#Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux1#RollbackPrepared")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#RollbackPrepared")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_twoPhaseCommit_failToCommitPrepared(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetQux()
+	defer resetQux()
+
+	WillFailToCommitQuxDaxConn["qux2"] = true
+
+	err := runTwoPhaseTxn(t)
+	switch r := err.Reason().(type) {
+	case FailToCommitDaxConn:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["qux2"].Reason(), FailToCommitQuxDaxConn{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "qux1#Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux1#CommitPrepared")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux1#ForceBack")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#ForceBack")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#RollbackPrepared")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+type FailToCloseDaxSrc struct{}
+type FailToCloseDaxConn struct{}
+
+type ErrCloseDaxSrc struct {
+	FooDaxSrc
+}
+
+func (ds ErrCloseDaxSrc) CloseWithErr() errs.Err {
+	Logs.PushBack("ErrCloseDaxSrc#CloseWithErr")
+	return errs.New(FailToCloseDaxSrc{})
+}
+
+func (ds ErrCloseDaxSrc) CreateDaxConn() (DaxConn, errs.Err) {
+	Logs.PushBack("ErrCloseDaxSrc#CreateDaxConn")
+	return ErrCloseDaxConn{FooDaxConn{client: &FooClient{}}}, errs.Ok()
+}
+
+type ErrCloseDaxConn struct {
+	FooDaxConn
+}
+
+func (conn ErrCloseDaxConn) CloseWithErr() errs.Err {
+	Logs.PushBack("ErrCloseDaxConn#CloseWithErr")
+	return errs.New(FailToCloseDaxConn{})
+}
+
+func TestClose_failToCloseGlobalDaxSrcs(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("foo", FooDaxSrc{})
+	Uses("err", ErrCloseDaxSrc{})
+
+	err := Setup()
+	assert.True(t, err.IsOk())
+
+	err = Close()
+	switch r := err.Reason().(type) {
+	case FailToCloseGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["err"].Reason(), FailToCloseDaxSrc{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "ErrCloseDaxSrc#CloseWithErr")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestStartApp_failToCloseGlobalDaxSrcs(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("err", ErrCloseDaxSrc{})
+
+	err := StartApp(func() errs.Err {
+		return errs.Ok()
+	})
+	switch r := err.Reason().(type) {
+	case FailToCloseGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["err"].Reason(), FailToCloseDaxSrc{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	type FailToDoSomething struct{}
+
+	Reset()
+	Uses("err", ErrCloseDaxSrc{})
+
+	err = StartApp(func() errs.Err {
+		return errs.New(FailToDoSomething{})
+	})
+	assert.IsType(t, err.Reason(), FailToDoSomething{})
+}
+
+func TestDaxBase_Close_failToCloseLocalDaxSrcs(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+
+	err := base.Uses("foo", FooDaxSrc{})
+	assert.True(t, err.IsOk())
+	err = base.Uses("err", ErrCloseDaxSrc{})
+	assert.True(t, err.IsOk())
+
+	err = base.Close()
+	switch r := err.Reason().(type) {
+	case FailToCloseLocalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["err"].Reason(), FailToCloseDaxSrc{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	err = base.Close()
+	assert.True(t, err.IsOk())
+}
+
+func TestDaxBase_Disuses_failToCloseLocalDaxSrc(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("err", ErrCloseDaxSrc{})
+	assert.True(t, err.IsOk())
+
+	err = base.Disuses("err")
+	switch r := err.Reason().(type) {
+	case FailToCloseLocalDaxSrc:
+		assert.Equal(t, r.Name, "err")
+		assert.IsType(t, err.Cause().(errs.Err).Reason(), FailToCloseDaxSrc{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	err = base.Disuses("err")
+	assert.True(t, err.IsOk())
+}
+
+func TestTxn_failToCloseDaxConns(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	var closeErr errs.Err
+	AddTxnHook(TxnHook{
+		OnCloseErr: func(err errs.Err) {
+			closeErr = err
+		},
+		AfterEnd: func(names []string, err errs.Err) {
+			Logs.PushBack(fmt.Sprintf("AfterEnd %v", names))
+		},
+	})
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("foo", FooDaxSrc{})
+	assert.True(t, err.IsOk())
+	err = base.Uses("err", ErrCloseDaxSrc{})
+	assert.True(t, err.IsOk())
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := dax.getDaxConn("foo")
+		assert.True(t, err.IsOk())
+		_, err = dax.getDaxConn("err")
+		assert.True(t, err.IsOk())
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+
+	switch r := closeErr.Reason().(type) {
+	case FailToCloseDaxConns:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["err"].Reason(), FailToCloseDaxConn{})
+	default:
+		assert.Fail(t, closeErr.Error())
+	}
+
+	assert.Equal(t, Logs.Back().Value, "AfterEnd [foo err]")
+}
+
+// depLogs is the log of DepDaxSrc(s) and their DaxConn(s), of which methods
+// are called from multiple goroutines.
+type depLogs struct {
+	mutex  sync.Mutex
+	values []string
+}
+
+func (l *depLogs) PushBack(v string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.values = append(l.values, v)
+}
+
+func (l *depLogs) Values() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	values := make([]string, len(l.values))
+	copy(values, l.values)
+	return values
+}
+
+func (l *depLogs) Init() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.values = nil
+}
+
+var DepLogs depLogs
+
+type FailToSetupDepDaxSrc struct{}
+
+type DepDaxSrc struct {
+	name  string
+	async bool
+	fail  bool
+}
+
+func (ds DepDaxSrc) Setup(ag AsyncGroup) errs.Err {
+	if ds.fail {
+		return errs.New(FailToSetupDepDaxSrc{})
+	}
+	if ds.async {
+		ag.Add(func() errs.Err {
+			time.Sleep(10 * time.Millisecond)
+			DepLogs.PushBack(ds.name + "#Setup")
+			return errs.Ok()
+		})
+		return errs.Ok()
+	}
+	DepLogs.PushBack(ds.name + "#Setup")
+	return errs.Ok()
+}
+
+func (ds DepDaxSrc) Close() {
+	DepLogs.PushBack(ds.name + "#Close")
+}
+
+func (ds DepDaxSrc) CreateDaxConn() (DaxConn, errs.Err) {
+	DepLogs.PushBack(ds.name + "#CreateDaxConn")
+	return &DepDaxConn{}, errs.Ok()
+}
+
+type DepDaxConn struct {
+	committed bool
+}
+
+func (conn *DepDaxConn) Commit(ag AsyncGroup) errs.Err {
+	DepLogs.PushBack("DepDaxConn#Commit")
+	conn.committed = true
+	return errs.Ok()
+}
+
+func (conn *DepDaxConn) IsCommitted() bool {
+	return conn.committed
+}
+
+func (conn *DepDaxConn) Rollback(ag AsyncGroup) {
+	DepLogs.PushBack("DepDaxConn#Rollback")
+}
+
+func (conn *DepDaxConn) ForceBack(ag AsyncGroup) {
+	DepLogs.PushBack("DepDaxConn#ForceBack")
+}
+
+func (conn *DepDaxConn) Close() {
+	DepLogs.PushBack("DepDaxConn#Close")
+}
+
+func TestSetup_withDependencies(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("publisher", DepDaxSrc{name: "publisher"}, "config", "database")
+	Uses("database", DepDaxSrc{name: "database"}, "config")
+	Uses("config", DepDaxSrc{name: "config", async: true})
+	Uses("cliargs", DepDaxSrc{name: "cliargs"})
+
+	err := Setup()
+	assert.True(t, err.IsOk())
+
+	err = Close()
+	assert.True(t, err.IsOk())
+
+	assert.Equal(t, DepLogs.Values(), []string{
+		"cliargs#Setup",
+		"config#Setup",
+		"database#Setup",
+		"publisher#Setup",
+		"publisher#Close",
+		"database#Close",
+		"cliargs#Close",
+		"config#Close",
+	})
+}
+
+func TestSetup_dependenciesAreCyclic(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("cliargs", DepDaxSrc{name: "cliargs"})
+	Uses("a", DepDaxSrc{name: "a"}, "c")
+	Uses("b", DepDaxSrc{name: "b"}, "a")
+	Uses("c", DepDaxSrc{name: "c"}, "b", "cliargs")
+
+	err := Setup()
+	switch r := err.Reason().(type) {
+	case DaxSrcDependenciesAreCyclic:
+		assert.Equal(t, r.Names, []string{"a", "b", "c"})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	assert.Equal(t, len(DepLogs.Values()), 0)
+}
+
+func TestSetup_dependedDaxSrcIsNotFound(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("database", DepDaxSrc{name: "database"}, "config")
+
+	err := Setup()
+	switch r := err.Reason().(type) {
+	case DependedDaxSrcIsNotFound:
+		assert.Equal(t, r.Name, "database")
+		assert.Equal(t, r.DependedName, "config")
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	assert.Equal(t, len(DepLogs.Values()), 0)
+}
+
+func TestSetup_notSetupDependentsIfDependedFails(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("database", DepDaxSrc{name: "database"}, "config")
+	Uses("config", DepDaxSrc{name: "config", fail: true})
+
+	err := Setup()
+	switch r := err.Reason().(type) {
+	case FailToSetupGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["config"].Reason(), FailToSetupDepDaxSrc{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	assert.Equal(t, DepLogs.Values(), []string{
+		"database#Close",
+		"config#Close",
+	})
+}
+
+// setupGauge counts Setup(s) or HealthCheck(s) of DaxSrc(s) running at the
+// same time.
+type setupGauge struct {
+	mutex   sync.Mutex
+	running int
+	peak    int
+}
+
+func (g *setupGauge) enter() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.running++
+	if g.running > g.peak {
+		g.peak = g.running
+	}
+}
+
+func (g *setupGauge) leave() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.running--
+}
+
+func (g *setupGauge) peakCount() int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.peak
+}
+
+func (g *setupGauge) runningCount() int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.running
+}
+
+type SlowDaxSrc struct {
+	name  string
+	wait  time.Duration
+	fail  bool
+	gauge *setupGauge
+	done  chan struct{}
+}
+
+func (ds SlowDaxSrc) Setup(ag AsyncGroup) errs.Err {
+	if ds.done != nil {
+		defer close(ds.done)
+	}
+	if ds.gauge != nil {
+		ds.gauge.enter()
+		defer ds.gauge.leave()
+	}
+	select {
+	case <-time.After(ds.wait):
+	case <-ContextOf(ag).Done():
+		return errs.New(FailToSetupFooDaxSrc{}, ContextOf(ag).Err())
+	}
+	if ds.fail {
+		return errs.New(FailToSetupFooDaxSrc{})
+	}
+	return errs.Ok()
+}
+
+func (ds SlowDaxSrc) Close() {}
+
+func (ds SlowDaxSrc) CreateDaxConn() (DaxConn, errs.Err) {
+	return FooDaxConn{client: &FooClient{}}, errs.Ok()
+}
+
+func TestSetup_withSetupConcurrency(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	gauge := &setupGauge{}
+
+	Uses("a", SlowDaxSrc{wait: 30 * time.Millisecond, gauge: gauge})
+	Uses("b", SlowDaxSrc{wait: 30 * time.Millisecond, gauge: gauge})
+	Uses("c", SlowDaxSrc{wait: 30 * time.Millisecond, gauge: gauge})
+	Uses("d", SlowDaxSrc{wait: 30 * time.Millisecond, gauge: gauge}, "a")
+
+	start := time.Now()
+	err := Setup(WithSetupConcurrency(3))
+	elapsed := time.Since(start)
+	assert.True(t, err.IsOk())
+	assert.True(t, elapsed >= 60*time.Millisecond)
+	assert.Equal(t, gauge.peakCount(), 3)
+}
+
+func TestSetup_withSetupConcurrency_limited(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	gauge := &setupGauge{}
+
+	Uses("a", SlowDaxSrc{wait: 30 * time.Millisecond, gauge: gauge})
+	Uses("b", SlowDaxSrc{wait: 30 * time.Millisecond, gauge: gauge})
+	Uses("c", SlowDaxSrc{wait: 30 * time.Millisecond, gauge: gauge})
+
+	start := time.Now()
+	err := Setup(WithSetupConcurrency(2))
+	elapsed := time.Since(start)
+	assert.True(t, err.IsOk())
+	assert.True(t, elapsed >= 60*time.Millisecond)
+	assert.Equal(t, gauge.peakCount(), 2)
+}
+
+func TestSetup_withSetupConcurrency_error(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	WillFailToSetupBarDaxSrc = true
+
+	Uses("a", SlowDaxSrc{wait: 10 * time.Millisecond, fail: true})
+	Uses("b", SlowDaxSrc{wait: 30 * time.Millisecond, fail: true})
+	Uses("c", &BarDaxSrc{})
+
+	err := Setup(WithSetupConcurrency(2))
+	switch r := err.Reason().(type) {
+	case FailToSetupGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 2)
+		assert.IsType(t, r.Errors["a"].Reason(), FailToSetupFooDaxSrc{})
+		assert.IsType(t, r.Errors["b"].Reason(), FailToSetupFooDaxSrc{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "BarDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestSetup_withDaxSrcSetupTimeout(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	done := make(chan struct{})
+	defer func() { <-done }()
+
+	Uses("a", SlowDaxSrc{wait: 10 * time.Millisecond})
+	Uses("b", SlowDaxSrc{wait: time.Hour, done: done})
+	Uses("c", SlowDaxSrc{wait: 10 * time.Millisecond})
+
+	err := Setup(
+		WithSetupConcurrency(3),
+		WithDaxSrcSetupTimeout(30*time.Millisecond),
+	)
+	switch r := err.Reason().(type) {
+	case FailToSetupGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.Equal(t, r.Errors["b"].Reason(), SetupTimedOut{Name: "b"})
+	default:
+		assert.Fail(t, err.Error())
+	}
+}
+
+type HungDaxSrc struct {
+	release chan struct{}
+	closed  chan struct{}
+	async   bool
+	gauge   *setupGauge
+}
+
+func (ds HungDaxSrc) Setup(ag AsyncGroup) errs.Err {
+	if ds.gauge != nil {
+		ds.gauge.enter()
+		defer ds.gauge.leave()
+	}
+	if ds.async {
+		ag.Add(func() errs.Err {
+			<-ds.release
+			return errs.Ok()
+		})
+		return errs.Ok()
+	}
+	<-ds.release
+	return errs.Ok()
+}
+
+func (ds HungDaxSrc) Close() {
+	if ds.closed != nil {
+		close(ds.closed)
+	}
+}
+
+func (ds HungDaxSrc) CreateDaxConn() (DaxConn, errs.Err) {
+	return FooDaxConn{client: &FooClient{}}, errs.Ok()
+}
+
+func TestSetup_daxSrcSetupTimedOut_sync(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	release := make(chan struct{})
+	closed := make(chan struct{})
+	gauge := &setupGauge{}
+
+	Uses("foo", FooDaxSrc{})
+	Uses("hung", HungDaxSrc{release: release, closed: closed, gauge: gauge})
+	Uses("bar", &BarDaxSrc{})
+
+	err := Setup(WithDaxSrcSetupTimeout(20 * time.Millisecond))
+	assert.Equal(t, gauge.runningCount(), 1)
+
+	switch r := err.Reason().(type) {
+	case FailToSetupGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.Equal(t, r.Errors["hung"].Reason(), SetupTimedOut{Name: "hung"})
+		assert.Equal(t, r.Errors["hung"].Cause(), context.DeadlineExceeded)
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxSrc#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+
+	select {
+	case <-closed:
+		assert.Fail(t, "closed before its Setup finishes")
+	default:
+	}
+
+	close(release)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "not closed after its Setup finishes")
+	}
+}
+
+func TestSetup_daxSrcSetupTimedOut_async(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	Uses("hung", HungDaxSrc{release: release, async: true})
+	Uses("foo", FooDaxSrc{})
+
+	err := Setup(WithDaxSrcSetupTimeout(20 * time.Millisecond))
+	switch r := err.Reason().(type) {
+	case FailToSetupGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.Equal(t, r.Errors["hung"].Reason(), SetupTimedOut{Name: "hung"})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestSetup_setupTimedOut(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	release := make(chan struct{})
+	defer close(release)
+	gauge := &setupGauge{}
+
+	Uses("foo", FooDaxSrc{})
+	Uses("hung", HungDaxSrc{release: release, gauge: gauge})
+	Uses("database", DepDaxSrc{name: "database"}, "hung")
+
+	err := Setup(WithSetupTimeout(20 * time.Millisecond))
+	assert.Equal(t, gauge.runningCount(), 1)
+
+	switch r := err.Reason().(type) {
+	case FailToSetupGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.Equal(t, r.Errors["hung"].Reason(), SetupTimedOut{Name: "hung"})
+		assert.Equal(t, r.Errors["hung"].Cause(), context.DeadlineExceeded)
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+
+	assert.Equal(t, DepLogs.Values(), []string{"database#Close"})
+}
+
+func TestSetupCtx_canceled(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	Uses("hung", HungDaxSrc{release: release})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	err := SetupCtx(ctx)
+	switch r := err.Reason().(type) {
+	case FailToSetupGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.Equal(t, r.Errors["hung"].Reason(), SetupTimedOut{Name: "hung"})
+		assert.Equal(t, r.Errors["hung"].Cause(), context.Canceled)
+	default:
+		assert.Fail(t, err.Error())
+	}
+}
+
+func TestStartApp_setupTimedOut(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	Uses("foo", FooDaxSrc{})
+	Uses("hung", HungDaxSrc{release: release})
+
+	app := func() errs.Err {
+		Logs.PushBack("app")
+		return errs.Ok()
+	}
+
+	err := StartApp(app, WithSetupTimeout(20*time.Millisecond))
+	switch r := err.Reason().(type) {
+	case FailToSetupGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.Equal(t, r.Errors["hung"].Reason(), SetupTimedOut{Name: "hung"})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
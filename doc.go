@@ -11,5 +11,11 @@ redundant parts based on the perspectives of the whole and the
 parts.
 The separation of logics and data accesses is the most prominent and
 fundamental part of this concept.
+
+Error reasons having the field Errors, like FailToCommitDaxConn, aggregate
+errs.Err(s) of multiple DaxSrc(s), DaxConn(s) or runners.
+An errs.Err of such a reason also has the aggregated errs.Err(s) as its causes
+in order of their keys, so that errors.Is, errs.ReasonAs and
+errs.Err#Situation can reach them.
 */
 package sabi
@@ -49,6 +49,23 @@
 //		...
 //	}
 //
+// Or ReasonAs function can be used to get a reason of a specified type from an
+// error and its cause chain.
+//
+//	if reason, ok := errs.ReasonAs[FailToDoSomething](err); ok {
+//		...
+//	}
+//
+// Err also works with errors.Is and errors.As of Go standard library.
+// errors.Is regards an Err as a target if their reasons are of the same type,
+// and errors.As extracts a reason which implements error or is assigned to an
+// interface type.
+// A target Err for errors.Is is created with Target function, which matches
+// Err(s) of which reasons are of the same type, and does not notify the
+// creation to handlers unlike New function.
+//
+//	if errors.Is(err, errs.Target(FailToDoSomething{})) { ... }
+//
 // # Error notifications
 //
 // This package support notification of error creations.
@@ -69,6 +86,7 @@
 package errs
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 )
@@ -78,9 +96,16 @@ import (
 // indicates a reason by which this error is caused.
 // A reason can has some fields that helps to know error situation where this
 // error is caused.
+//
+// An Err can have multiple causes.
+// Get, Situation and Error methods traverse all causes.
+// Unwrap method returns only the first cause, and Is and As methods check the
+// other causes, so that errors.Is and errors.As traverse all causes.
 type Err struct {
-	reason any
-	cause  error
+	reason   any
+	cause    error
+	causes   *[]error
+	isTarget bool
 }
 
 var ok = Err{}
@@ -90,15 +115,25 @@ func Ok() Err {
 	return ok
 }
 
-// New is the function which creates a new Err with a specified reason and an
-// optional cause.
+// New is the function which creates a new Err with a specified reason and
+// optional causes.
 // A reason is a struct type of which name expresses what is a reason.
+// Nil causes are ignored.
 func New(reason any, cause ...error) Err {
 	var e Err
 	e.reason = reason
 
-	if len(cause) > 0 {
-		e.cause = cause[0]
+	var causes []error
+	for _, c := range cause {
+		if c != nil {
+			causes = append(causes, c)
+		}
+	}
+	if len(causes) > 0 {
+		e.cause = causes[0]
+	}
+	if len(causes) > 1 {
+		e.causes = &causes
 	}
 
 	notifyErr(e)
@@ -106,6 +141,16 @@ func New(reason any, cause ...error) Err {
 	return e
 }
 
+// Target is the function which creates an Err with a specified reason, to be
+// used as a target of errors.Is.
+// errors.Is regards an Err as this target if their reasons are of the same
+// type, regardless of values of the reasons.
+// Unlike New function, this function does not notify the creation of the Err
+// to handlers, because the Err does not indicate an occured error.
+func Target(reason any) Err {
+	return Err{reason: reason, isTarget: true}
+}
+
 // IsOk is the method that checks whether this Err indicates there is no error.
 func (e Err) IsOk() bool {
 	return (e.reason == nil)
@@ -157,6 +202,8 @@ func (e Err) ReasonPackage() string {
 
 // Error is the method to get a string that expresses the content of this
 // error.
+// Causes which are held in fields of the reason, like the values of a map of
+// Err(s), are output only as the fields.
 func (e Err) Error() string {
 	if e.reason == nil {
 		return "{reason=nil}"
@@ -171,6 +218,8 @@ func (e Err) Error() string {
 
 	s := "{reason=" + t.Name()
 
+	var held []Err
+
 	n := v.NumField()
 	for i := 0; i < n; i++ {
 		k := t.Field(i).Name
@@ -178,31 +227,142 @@ func (e Err) Error() string {
 		f := v.Field(i)
 		if f.CanInterface() { // false if the field is nor public
 			s += ", " + k + "=" + fmt.Sprintf("%v", f.Interface())
+			held = collectErrs(f, held)
 		}
 	}
 
-	if e.cause != nil {
-		s += ", cause=" + e.cause.Error()
+	var causes []error
+	e.eachCause(func(c error) bool {
+		if !isHeldErr(c, held) {
+			causes = append(causes, c)
+		}
+		return true
+	})
+
+	if e.causes != nil && len(causes) > 0 {
+		s += ", causes=["
+		for i, c := range causes {
+			if i > 0 {
+				s += ", "
+			}
+			s += c.Error()
+		}
+		s += "]"
+	} else if len(causes) > 0 {
+		s += ", cause=" + causes[0].Error()
 	}
 
 	s += "}"
 	return s
 }
 
+// collectErrs appends Err(s) which the argument field value of a reason is or
+// holds as elements of a map, a slice, or an array.
+func collectErrs(v reflect.Value, held []Err) []Err {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			held = collectErrs(v.Elem(), held)
+		}
+	case reflect.Struct:
+		if e, ok := v.Interface().(Err); ok {
+			held = append(held, e)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			held = collectErrs(iter.Value(), held)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			held = collectErrs(v.Index(i), held)
+		}
+	}
+	return held
+}
+
+// heldErrsOf collects Err(s) held in public fields of the argument reason.
+func heldErrsOf(v reflect.Value) []Err {
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var held []Err
+
+	n := v.NumField()
+	for i := 0; i < n; i++ {
+		f := v.Field(i)
+		if f.CanInterface() {
+			held = collectErrs(f, held)
+		}
+	}
+	return held
+}
+
+// isHeldErr checks whether the argument cause is one of the argument Err(s)
+// held in fields of a reason, so that Error method does not output it twice
+// and Get and Situation methods do not mix fields of sibling errors.
+func isHeldErr(cause error, held []Err) bool {
+	e, ok := cause.(Err)
+	if !ok {
+		return false
+	}
+	for _, h := range held {
+		if reflect.DeepEqual(e, h) {
+			return true
+		}
+	}
+	return false
+}
+
 // Unwrap is the method to get an error which is wrapped in this error.
+// If this Err has multiple causes, this method returns the first one, and
+// the other causes are checked by Is and As methods.
 func (e Err) Unwrap() error {
 	return e.cause
 }
 
 // Cause is the method to get the causal error of this Err.
+// If this Err has multiple causes, this method returns the first one.
 func (e Err) Cause() error {
 	return e.cause
 }
 
+// Causes is the method to get all causal errors of this Err in order of
+// their specification.
+// If this Err has no cause, this method returns nil.
+func (e Err) Causes() []error {
+	if e.causes != nil {
+		causes := make([]error, len(*e.causes))
+		copy(causes, *e.causes)
+		return causes
+	}
+	if e.cause != nil {
+		return []error{e.cause}
+	}
+	return nil
+}
+
+func (e Err) eachCause(fn func(c error) bool) {
+	if e.causes != nil {
+		for _, c := range *e.causes {
+			if !fn(c) {
+				return
+			}
+		}
+	} else if e.cause != nil {
+		fn(e.cause)
+	}
+}
+
 // Get is the method to get a field value of the reason struct type by the
 // specified name.
 // If the specified named field is not found in the reason of this Err, this
-// method finds a same named field in reasons of cause errors hierarchically.
+// method finds a same named field in reasons of cause errors hierarchically,
+// in order of the causes.
+// Causes which are held in fields of the reason, like errors of an aggregated
+// Err, are not searched because they are siblings of each other and are got
+// through the field.
 func (e Err) Get(name string) any {
 	if e.reason == nil {
 		return nil
@@ -218,18 +378,28 @@ func (e Err) Get(name string) any {
 		return f.Interface()
 	}
 
-	if e.cause != nil {
-		c, ok := e.cause.(Err)
-		if ok {
-			return c.Get(name)
+	held := heldErrsOf(v)
+
+	var value any
+	e.eachCause(func(cause error) bool {
+		if c, ok := cause.(Err); ok && !isHeldErr(c, held) {
+			value = c.Get(name)
 		}
-	}
+		return (value == nil)
+	})
 
-	return nil
+	return value
 }
 
 // Situation is the method to get a map which contains parameters that
 // represents error situation.
+// The map contains fields of reasons of this Err and its causes
+// hierarchically.
+// If a same named field is in multiple reasons, the field of this Err's
+// reason or of the former cause takes priority.
+// Causes which are held in fields of the reason, like errors of an aggregated
+// Err, are not merged into the map, because same named fields of those sibling
+// errors would overwrite each other.
 func (e Err) Situation() map[string]any {
 	var m map[string]any
 
@@ -242,10 +412,21 @@ func (e Err) Situation() map[string]any {
 		v = v.Elem()
 	}
 
-	if e.cause != nil {
-		c, ok := e.cause.(Err)
-		if ok {
-			m = c.Situation()
+	held := heldErrsOf(v)
+
+	causes := e.Causes()
+	for i := len(causes) - 1; i >= 0; i-- {
+		c, ok := causes[i].(Err)
+		if !ok || isHeldErr(c, held) {
+			continue
+		}
+		cm := c.Situation()
+		if m == nil {
+			m = cm
+			continue
+		}
+		for k, v := range cm {
+			m[k] = v
 		}
 	}
 
@@ -266,3 +447,172 @@ func (e Err) Situation() map[string]any {
 
 	return m
 }
+
+// Is is the method which is used by errors.Is to check whether this Err
+// matches the argument target.
+// If the target is an Err created by Target function, this method returns
+// true when the reasons of this Err and the target are of the same type, and
+// a value and a pointer of a reason struct type are regarded as the same type.
+// Otherwise, this method returns true when the reason of this Err equals to
+// the reason of the target Err or to the target itself, or when the reason
+// has Is method which returns true for the target.
+// The chain of the first cause is not checked by this method because
+// errors.Is traverses it, but the other causes are checked by this method.
+func (e Err) Is(target error) bool {
+	if e.reason == nil {
+		return false
+	}
+	if t, ok := target.(Err); ok {
+		if t.reason != nil {
+			if t.isTarget {
+				if reasonTypeOf(e.reason) == reasonTypeOf(t.reason) {
+					return true
+				}
+			} else if reasonIs(e.reason, t.reason) {
+				return true
+			}
+		}
+	} else if reasonIs(e.reason, target) {
+		return true
+	}
+
+	if e.causes != nil {
+		for _, c := range (*e.causes)[1:] {
+			if errors.Is(c, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reasonIs checks whether the argument reason equals to the argument target,
+// or has Is method which returns true for the target.
+func reasonIs(reason, target any) bool {
+	if reflect.TypeOf(reason).Comparable() && reason == target {
+		return true
+	}
+	if r, ok := reason.(interface{ Is(error) bool }); ok {
+		if t, ok := target.(error); ok {
+			return r.Is(t)
+		}
+	}
+	return false
+}
+
+// As is the method which is used by errors.As to set the reason of this Err
+// to the argument target, which is a non-nil pointer.
+// If the reason is a value or a pointer of the type which the target points
+// to, or is assignable to it, this method sets the reason and returns true.
+// The chain of the first cause is not checked by this method because
+// errors.As traverses it, but the other causes are checked by this method.
+func (e Err) As(target any) bool {
+	if e.reason == nil || target == nil {
+		return false
+	}
+	tv := reflect.ValueOf(target)
+	if tv.Kind() != reflect.Ptr || tv.IsNil() {
+		return false
+	}
+	rv, ok := convertReason(e.reason, tv.Type().Elem())
+	if ok {
+		tv.Elem().Set(rv)
+		return true
+	}
+
+	if e.causes != nil {
+		for _, c := range (*e.causes)[1:] {
+			if errors.As(c, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReasonAs is the function to get a reason of the type parameter from the
+// argument error and its cause chain.
+// Multiple causes are traversed in depth-first order.
+// A reason which is a value or a pointer of the type parameter, or is
+// assignable to it, matches.
+// If no reason matches, this function returns the zero value and false.
+func ReasonAs[T any](err error) (T, bool) {
+	var zero T
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	if r, ok := reasonAs[T](err, t); ok {
+		return r, true
+	}
+	return zero, false
+}
+
+func reasonAs[T any](err error, t reflect.Type) (T, bool) {
+	var zero T
+	if err == nil {
+		return zero, false
+	}
+
+	if e, ok := err.(Err); ok {
+		if e.reason != nil {
+			if rv, ok := convertReason(e.reason, t); ok {
+				return rv.Interface().(T), true
+			}
+		}
+		var r T
+		found := false
+		e.eachCause(func(c error) bool {
+			r, found = reasonAs[T](c, t)
+			return !found
+		})
+		return r, found
+	} else if r, ok := err.(T); ok {
+		return r, true
+	}
+
+	switch u := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, c := range u.Unwrap() {
+			if r, ok := reasonAs[T](c, t); ok {
+				return r, true
+			}
+		}
+	case interface{ Unwrap() error }:
+		return reasonAs[T](u.Unwrap(), t)
+	}
+
+	return zero, false
+}
+
+func reasonTypeOf(reason any) reflect.Type {
+	t := reflect.TypeOf(reason)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// convertReason converts the argument reason to a reflect.Value of the
+// argument type, regarding a value and a pointer of a reason struct type as
+// convertible to each other.
+func convertReason(reason any, t reflect.Type) (reflect.Value, bool) {
+	rv := reflect.ValueOf(reason)
+	rt := rv.Type()
+
+	if rt.AssignableTo(t) {
+		v := reflect.New(t).Elem()
+		v.Set(rv)
+		return v, true
+	}
+	if rt.Kind() == reflect.Ptr && rt.Elem() == t {
+		if rv.IsNil() {
+			return reflect.Value{}, false
+		}
+		return rv.Elem(), true
+	}
+	if t.Kind() == reflect.Ptr && t.Elem() == rt {
+		p := reflect.New(rt)
+		p.Elem().Set(rv)
+		return p, true
+	}
+	return reflect.Value{}, false
+}
@@ -2,6 +2,7 @@ package errs_test
 
 import (
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -281,3 +282,281 @@ func TestErr_IfOk_error(t *testing.T) {
 		assert.Fail(t, e1.Error())
 	}
 }
+
+type ReasonError struct {
+	Code int
+}
+
+func (r ReasonError) Error() string {
+	return "ReasonError"
+}
+
+type reasonNamer interface {
+	Name() string
+}
+
+type NamedReason struct{}
+
+func (r NamedReason) Name() string {
+	return "named"
+}
+
+func TestErr_Is(t *testing.T) {
+	e := errs.New(FailToGetValue{Name: "foo"}, errs.New(InvalidValue{Value: "x"}))
+
+	assert.True(t, errors.Is(e, errs.Target(FailToGetValue{})))
+	assert.True(t, errors.Is(e, errs.Target(&FailToGetValue{})))
+	assert.True(t, errors.Is(e, errs.Target(InvalidValue{})))
+	assert.True(t, errors.Is(e, errs.Target(&InvalidValue{})))
+	assert.False(t, errors.Is(e, errs.Target(NamedReason{})))
+	assert.False(t, errors.Is(e, errs.Ok()))
+	assert.False(t, errors.Is(errs.Ok(), errs.Target(FailToGetValue{})))
+
+	pe := errs.New(&FailToGetValue{Name: "foo"})
+	assert.True(t, errors.Is(pe, errs.Target(FailToGetValue{})))
+
+	assert.True(t, errors.Is(e, errs.Target(FailToGetValue{Name: "bar"})))
+	assert.True(t, errors.Is(e, errs.New(FailToGetValue{Name: "foo"})))
+	assert.False(t, errors.Is(e, errs.New(FailToGetValue{Name: "bar"})))
+	assert.False(t, errors.Is(e, errs.New(InvalidValue{Value: "y"})))
+
+	re := errs.New(FailToGetValue{}, errs.New(ReasonError{Code: 1}))
+	assert.True(t, errors.Is(re, ReasonError{Code: 1}))
+	assert.False(t, errors.Is(re, ReasonError{Code: 2}))
+	assert.False(t, errors.Is(re, &ReasonError{Code: 1}))
+	assert.False(t, errors.Is(re, InvalidValueError{}))
+
+	pre := &ReasonError{Code: 1}
+	re = errs.New(FailToGetValue{}, errs.New(pre))
+	assert.True(t, errors.Is(re, pre))
+	assert.False(t, errors.Is(re, &ReasonError{Code: 1}))
+
+	ee := errs.New(io.EOF)
+	assert.True(t, errors.Is(ee, io.EOF))
+	assert.False(t, errors.Is(ee, io.ErrUnexpectedEOF))
+
+	ie := errs.New(IsReason{Code: 1})
+	assert.True(t, errors.Is(ie, ReasonError{Code: 1}))
+	assert.False(t, errors.Is(ie, ReasonError{Code: 2}))
+}
+
+type IsReason struct {
+	Code int
+}
+
+func (r IsReason) Is(target error) bool {
+	t, ok := target.(ReasonError)
+	return ok && t.Code == r.Code
+}
+
+func TestTarget(t *testing.T) {
+	count := 0
+	n := errs.NewNotifier()
+	n.AddSyncHandler(func(e errs.Err, o errs.ErrOcc) { count++ })
+	n.FixCfg()
+	defer n.Reset()
+
+	target := errs.Target(FailToGetValue{})
+	assert.Equal(t, count, 0)
+	assert.True(t, target.IsNotOk())
+	assert.Nil(t, target.Cause())
+
+	e := errs.New(FailToGetValue{Name: "foo"})
+	assert.Equal(t, count, 1)
+	assert.True(t, errors.Is(e, target))
+}
+
+func TestErr_As(t *testing.T) {
+	e := errs.New(FailToGetValue{Name: "foo"}, errs.New(ReasonError{Code: 1}))
+
+	var re ReasonError
+	assert.True(t, errors.As(e, &re))
+	assert.Equal(t, re, ReasonError{Code: 1})
+
+	var pre *ReasonError
+	assert.True(t, errors.As(e, &pre))
+	assert.Equal(t, *pre, ReasonError{Code: 1})
+
+	var ive InvalidValueError
+	assert.False(t, errors.As(e, &ive))
+
+	pe := errs.New(FailToGetValue{}, errs.New(&ReasonError{Code: 2}))
+	assert.True(t, errors.As(pe, &re))
+	assert.Equal(t, re, ReasonError{Code: 2})
+	assert.True(t, errors.As(pe, &pre))
+	assert.Equal(t, *pre, ReasonError{Code: 2})
+
+	var fgv FailToGetValue
+	assert.True(t, e.As(&fgv))
+	assert.Equal(t, fgv, FailToGetValue{Name: "foo"})
+	assert.False(t, e.As(fgv))
+	assert.False(t, e.As(nil))
+	assert.False(t, errs.Ok().As(&fgv))
+
+	ne := errs.New(NamedReason{})
+	var namer reasonNamer
+	assert.True(t, errors.As(ne, &namer))
+	assert.Equal(t, namer.Name(), "named")
+}
+
+func TestReasonAs(t *testing.T) {
+	e := errs.New(FailToGetValue{Name: "foo"},
+		errs.New(&InvalidValue{Value: "x"}, InvalidValueError{Value: "y"}))
+
+	r1, ok := errs.ReasonAs[FailToGetValue](e)
+	assert.True(t, ok)
+	assert.Equal(t, r1, FailToGetValue{Name: "foo"})
+
+	r2, ok := errs.ReasonAs[*FailToGetValue](e)
+	assert.True(t, ok)
+	assert.Equal(t, *r2, FailToGetValue{Name: "foo"})
+
+	r3, ok := errs.ReasonAs[InvalidValue](e)
+	assert.True(t, ok)
+	assert.Equal(t, r3, InvalidValue{Value: "x"})
+
+	r4, ok := errs.ReasonAs[*InvalidValue](e)
+	assert.True(t, ok)
+	assert.Equal(t, *r4, InvalidValue{Value: "x"})
+
+	r5, ok := errs.ReasonAs[InvalidValueError](e)
+	assert.True(t, ok)
+	assert.Equal(t, r5, InvalidValueError{Value: "y"})
+
+	r6, ok := errs.ReasonAs[NamedReason](e)
+	assert.False(t, ok)
+	assert.Equal(t, r6, NamedReason{})
+
+	r7, ok := errs.ReasonAs[reasonNamer](errs.New(FailToGetValue{}, errs.New(NamedReason{})))
+	assert.True(t, ok)
+	assert.Equal(t, r7.Name(), "named")
+
+	_, ok = errs.ReasonAs[FailToGetValue](nil)
+	assert.False(t, ok)
+	_, ok = errs.ReasonAs[FailToGetValue](errs.Ok())
+	assert.False(t, ok)
+}
+
+func TestErr_New_multipleCauses(t *testing.T) {
+	cause1 := errs.New(InvalidValue{Value: "x"})
+	cause2 := errors.New("def")
+	cause3 := errs.New(FailToGetValue{Name: "bar"}, InvalidValueError{Value: "y"})
+
+	e := errs.New(FailToGetValue{Name: "foo"}, cause1, nil, cause2, cause3)
+
+	assert.Equal(t, e.Error(), "{reason=FailToGetValue, Name=foo, causes=["+
+		"{reason=InvalidValue, Value=x}, def, "+
+		"{reason=FailToGetValue, Name=bar, cause=InvalidValue{Value=y}}]}")
+
+	assert.Equal(t, e.Cause(), cause1)
+	assert.Equal(t, e.Causes(), []error{cause1, cause2, cause3})
+	assert.Equal(t, e.Unwrap(), cause1)
+	assert.Equal(t, errors.Unwrap(e), cause1)
+
+	causes := e.Causes()
+	causes[0] = nil
+	assert.Equal(t, e.Cause(), cause1)
+	assert.Equal(t, e.Causes()[0], cause1)
+
+	assert.Equal(t, e.Get("Name"), "foo")
+	assert.Equal(t, e.Get("Value"), "x")
+	assert.Nil(t, e.Get("Other"))
+
+	m := e.Situation()
+	assert.Equal(t, len(m), 2)
+	assert.Equal(t, m["Name"], "foo")
+	assert.Equal(t, m["Value"], "x")
+
+	assert.True(t, errors.Is(e, cause2))
+	assert.True(t, errors.Is(e, errs.Target(InvalidValue{})))
+	assert.True(t, errors.Is(e, InvalidValueError{Value: "y"}))
+	assert.False(t, errors.Is(e, errors.New("def")))
+
+	var ive InvalidValueError
+	assert.True(t, errors.As(e, &ive))
+	assert.Equal(t, ive, InvalidValueError{Value: "y"})
+
+	r, ok := errs.ReasonAs[InvalidValue](e)
+	assert.True(t, ok)
+	assert.Equal(t, r, InvalidValue{Value: "x"})
+
+	r2, ok := errs.ReasonAs[InvalidValueError](e)
+	assert.True(t, ok)
+	assert.Equal(t, r2, InvalidValueError{Value: "y"})
+}
+
+func TestErr_New_nilCauses(t *testing.T) {
+	e := errs.New(InvalidValue{Value: "x"}, nil, nil)
+
+	assert.Nil(t, e.Cause())
+	assert.Nil(t, e.Causes())
+	assert.Equal(t, e.Error(), "{reason=InvalidValue, Value=x}")
+
+	cause := errors.New("abc")
+	e = errs.New(InvalidValue{Value: "x"}, nil, cause)
+
+	assert.Equal(t, e.Cause(), cause)
+	assert.Equal(t, e.Causes(), []error{cause})
+	assert.Equal(t, e.Error(), "{reason=InvalidValue, Value=x, cause=abc}")
+}
+
+func TestErr_Situation_multipleCausesPriority(t *testing.T) {
+	type Reason1 struct{ A, B string }
+	type Reason2 struct{ B, C string }
+	type Reason3 struct{ C, D string }
+
+	e := errs.New(Reason1{A: "a1", B: "b1"},
+		errs.New(Reason2{B: "b2", C: "c2"}),
+		errs.New(Reason3{C: "c3", D: "d3"}))
+
+	assert.Equal(t, e.Situation(), map[string]any{
+		"A": "a1", "B": "b1", "C": "c2", "D": "d3",
+	})
+	assert.Equal(t, e.Get("C"), "c2")
+	assert.Equal(t, e.Get("D"), "d3")
+}
+
+func TestErr_Situation_causesHeldByReason(t *testing.T) {
+	type FailToGetValues struct {
+		Errors map[string]errs.Err
+	}
+
+	e1 := errs.New(FailToGetValue{Name: "foo"})
+	e2 := errs.New(FailToGetValue{Name: "bar"})
+	m := map[string]errs.Err{"a": e1, "b": e2}
+
+	e := errs.New(FailToGetValues{Errors: m}, e1, e2)
+	assert.Equal(t, e.Situation(), map[string]any{"Errors": m})
+	assert.Nil(t, e.Get("Name"))
+
+	cause := errs.New(InvalidValue{Value: "x"})
+	e = errs.New(FailToGetValues{Errors: m}, e1, cause, e2)
+	assert.Equal(t, e.Situation(), map[string]any{"Errors": m, "Value": "x"})
+	assert.Nil(t, e.Get("Name"))
+	assert.Equal(t, e.Get("Value"), "x")
+}
+
+func TestErr_Error_causesHeldByReason(t *testing.T) {
+	type FailToGetValues struct {
+		Errors map[string]errs.Err
+	}
+
+	e1 := errs.New(InvalidValue{Value: "x"})
+	e2 := errs.New(InvalidValue{Value: "y"})
+	m := map[string]errs.Err{"a": e1, "b": e2}
+
+	e := errs.New(FailToGetValues{Errors: m}, e1, e2)
+	assert.Equal(t, e.Error(), "{reason=FailToGetValues, Errors=map["+
+		"a:{reason=InvalidValue, Value=x} b:{reason=InvalidValue, Value=y}]}")
+	assert.Equal(t, e.Causes(), []error{e1, e2})
+
+	e = errs.New(FailToGetValues{Errors: map[string]errs.Err{"a": e1}}, e1)
+	assert.Equal(t, e.Error(), "{reason=FailToGetValues, Errors=map["+
+		"a:{reason=InvalidValue, Value=x}]}")
+
+	cause := errors.New("def")
+	e = errs.New(FailToGetValues{Errors: m}, e1, cause, e2)
+	assert.Equal(t, e.Error(), "{reason=FailToGetValues, Errors=map["+
+		"a:{reason=InvalidValue, Value=x} b:{reason=InvalidValue, Value=y}], "+
+		"causes=[def]}")
+}
@@ -226,3 +226,48 @@ func ExampleErr_IfOk() {
 	// Output:
 	// execute if non error.
 }
+
+func ExampleErr_Is() {
+	type FailToDoSomething struct{}
+	type FailToDoOtherThing struct{}
+
+	err := errs.New(FailToDoSomething{})
+
+	fmt.Printf("errors.Is(err, FailToDoSomething) = %v\n",
+		errors.Is(err, errs.Target(FailToDoSomething{})))
+	fmt.Printf("errors.Is(err, FailToDoOtherThing) = %v\n",
+		errors.Is(err, errs.Target(FailToDoOtherThing{})))
+	// Output:
+	// errors.Is(err, FailToDoSomething) = true
+	// errors.Is(err, FailToDoOtherThing) = false
+}
+
+func ExampleReasonAs() {
+	type FailToDoSomething struct{ Name string }
+	type FailToDoOtherThing struct{}
+
+	cause := errs.New(FailToDoSomething{Name: "abc"})
+	err := errs.New(FailToDoOtherThing{}, cause)
+
+	reason, ok := errs.ReasonAs[FailToDoSomething](err)
+	fmt.Printf("reason = %v, ok = %v\n", reason, ok)
+	// Output:
+	// reason = {abc}, ok = true
+}
+
+func ExampleErr_Causes() {
+	type FailToDoSomething struct{}
+
+	cause1 := errors.New("Causal error 1")
+	cause2 := errors.New("Causal error 2")
+
+	err := errs.New(FailToDoSomething{}, cause1, cause2)
+
+	fmt.Printf("%v\n", err.Causes())
+	fmt.Printf("%v\n", err)
+	fmt.Printf("errors.Is(err, cause2) = %v\n", errors.Is(err, cause2))
+	// Output:
+	// [Causal error 1 Causal error 2]
+	// {reason=FailToDoSomething, causes=[Causal error 1, Causal error 2]}
+	// errors.Is(err, cause2) = true
+}
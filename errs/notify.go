@@ -7,15 +7,35 @@ package errs
 import (
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
 	"time"
 )
 
 // ErrOcc is the struct type that contains time and position in a source
 // file when an Err occured.
+//
+// The full path of the source file, the function, the package, and the stack
+// trace are captured only if they are enabled with EnableCallerInfo and
+// EnableStackTrace before FixCfg.
 type ErrOcc struct {
-	time time.Time
-	file string
-	line int
+	time     time.Time
+	file     string
+	line     int
+	path     string
+	function string
+	pkg      string
+	stack    *[]StackFrame
+}
+
+// StackFrame is the struct type that contains a function and a position in a
+// source file of a frame of a stack trace.
+// Function is the package-qualified function name, and File is the full path
+// of the source file.
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
 }
 
 // Time is the method to get time when this Err occured.
@@ -33,6 +53,40 @@ func (e ErrOcc) File() string {
 	return e.file
 }
 
+// Path is the method to get the full path of the source file where this Err
+// occured.
+// This method returns an empty string if caller informations are not enabled.
+func (e ErrOcc) Path() string {
+	return e.path
+}
+
+// Func is the method to get the name of the function where this Err occured.
+// The name does not include the package path, and is like "DoSomething" or
+// "(*Foo).DoSomething".
+// This method returns an empty string if caller informations are not enabled.
+func (e ErrOcc) Func() string {
+	return e.function
+}
+
+// Package is the method to get the path of the package where this Err
+// occured.
+// This method returns an empty string if caller informations are not enabled.
+func (e ErrOcc) Package() string {
+	return e.pkg
+}
+
+// Stack is the method to get the stack trace from the function where this Err
+// occured.
+// This method returns nil if the stack trace is not enabled.
+func (e ErrOcc) Stack() []StackFrame {
+	if e.stack == nil {
+		return nil
+	}
+	stack := make([]StackFrame, len(*e.stack))
+	copy(stack, *e.stack)
+	return stack
+}
+
 type handlerListEntry struct {
 	handler func(Err, ErrOcc)
 	next    *handlerListEntry
@@ -43,81 +97,291 @@ type handlerList struct {
 	last *handlerListEntry
 }
 
+func (list *handlerList) add(handler func(Err, ErrOcc)) {
+	last := list.last
+	list.last = &handlerListEntry{handler, nil}
+
+	if last != nil {
+		last.next = list.last
+	}
+
+	if list.head == nil {
+		list.head = list.last
+	}
+}
+
+// Notifier is the struct type that holds Err creation event handlers.
+// A Notifier notifies its handlers of Err creations only while its
+// configuration is fixed with FixCfg method.
+//
+// The package-level functions: AddSyncHandler, AddAsyncHandler and FixCfg
+// configure the default Notifier, which can be got with DefaultNotifier
+// function.
+// Notifier(s) created with NewNotifier function are notified in addition to
+// the default Notifier, so that multiple configurations can coexist.
+// Every fixed Notifier is notified of all Err(s) created in the process.
+type Notifier struct {
+	syncHandlers  handlerList
+	asyncHandlers handlerList
+	isFixed       bool
+	callerInfo    bool
+	stackDepth    int
+}
+
 var (
-	syncErrHandlers  = handlerList{nil, nil}
-	asyncErrHandlers = handlerList{nil, nil}
-	isErrCfgFixed    = false
+	defaultNotifier Notifier
+
+	activeNotifiers      []*Notifier
+	activeNotifiersMutex sync.RWMutex
 )
 
-// AddSyncHandler is the function that adds an Err creation event handler.
+// DefaultNotifier is the function to get the default Notifier which the
+// package-level functions configure.
+func DefaultNotifier() *Notifier {
+	return &defaultNotifier
+}
+
+// NewNotifier is the function that creates a new Notifier instance.
+func NewNotifier() *Notifier {
+	return &Notifier{}
+}
+
+// AddSyncHandler is the method that adds an Err creation event handler.
 // Handlers added with this method are executed synchronously in the order of
 // addition.
-func AddSyncHandler(handler func(Err, ErrOcc)) {
-	if isErrCfgFixed {
+func (n *Notifier) AddSyncHandler(handler func(Err, ErrOcc)) {
+	if n.isFixed {
 		return
 	}
+	n.syncHandlers.add(handler)
+}
 
-	last := syncErrHandlers.last
-	syncErrHandlers.last = &handlerListEntry{handler, nil}
+// AddAsyncHandler is the method that adds an Err creation event handler.
+// Handlers added with this method are executed asynchronously.
+func (n *Notifier) AddAsyncHandler(handler func(Err, ErrOcc)) {
+	if n.isFixed {
+		return
+	}
+	n.asyncHandlers.add(handler)
+}
 
-	if last != nil {
-		last.next = syncErrHandlers.last
+// EnableCallerInfo is the method to make ErrOcc(s) passed to handlers of this
+// Notifier have the full path of the source file, the function, and the
+// package where an Err occured.
+// This method is ignored after FixCfg method is called.
+func (n *Notifier) EnableCallerInfo() {
+	if n.isFixed {
+		return
 	}
+	n.callerInfo = true
+}
 
-	if syncErrHandlers.head == nil {
-		syncErrHandlers.head = syncErrHandlers.last
+// EnableStackTrace is the method to make ErrOcc(s) passed to handlers of this
+// Notifier have a stack trace of which the maximum number of frames is the
+// argument depth.
+// If the depth is zero or less, the stack trace is disabled.
+// This method is ignored after FixCfg method is called.
+func (n *Notifier) EnableStackTrace(depth int) {
+	if n.isFixed {
+		return
 	}
+	if depth < 0 {
+		depth = 0
+	}
+	n.stackDepth = depth
 }
 
-// AddAsyncHandler is the function that adds an Err creation event handler.
-// Handlers added with this method are executed asynchronously.
-func AddAsyncHandler(handler func(Err, ErrOcc)) {
-	if isErrCfgFixed {
+// FixCfg is the method to fix the configuration of this Notifier.
+// After calling this method, handlers cannot be added any more and the
+// notification becomes effective.
+func (n *Notifier) FixCfg() {
+	if n.isFixed {
 		return
 	}
+	n.isFixed = true
 
-	last := asyncErrHandlers.last
-	asyncErrHandlers.last = &handlerListEntry{handler, nil}
+	if n == &defaultNotifier {
+		return
+	}
 
-	if last != nil {
-		last.next = asyncErrHandlers.last
+	activeNotifiersMutex.Lock()
+	defer activeNotifiersMutex.Unlock()
+
+	notifiers := make([]*Notifier, len(activeNotifiers), len(activeNotifiers)+1)
+	copy(notifiers, activeNotifiers)
+	activeNotifiers = append(notifiers, n)
+}
+
+// Reset is the method that removes all handlers of this Notifier and releases
+// the fixed configuration, so that this Notifier can be configured again.
+// This Notifier stops notifying until FixCfg method is called again.
+func (n *Notifier) Reset() {
+	if n != &defaultNotifier {
+		activeNotifiersMutex.Lock()
+		notifiers := make([]*Notifier, 0, len(activeNotifiers))
+		for _, a := range activeNotifiers {
+			if a != n {
+				notifiers = append(notifiers, a)
+			}
+		}
+		activeNotifiers = notifiers
+		activeNotifiersMutex.Unlock()
 	}
 
-	if asyncErrHandlers.head == nil {
-		asyncErrHandlers.head = asyncErrHandlers.last
+	n.syncHandlers = handlerList{nil, nil}
+	n.asyncHandlers = handlerList{nil, nil}
+	n.isFixed = false
+	n.callerInfo = false
+	n.stackDepth = 0
+}
+
+func (n *Notifier) hasHandlers() bool {
+	return n.isFixed && (n.syncHandlers.head != nil || n.asyncHandlers.head != nil)
+}
+
+func (n *Notifier) notify(err Err, occ ErrOcc) {
+	for el := n.syncHandlers.head; el != nil; el = el.next {
+		el.handler(err, occ)
+	}
+
+	for el := n.asyncHandlers.head; el != nil; el = el.next {
+		go el.handler(err, occ)
 	}
 }
 
+// AddSyncHandler is the function that adds an Err creation event handler.
+// Handlers added with this method are executed synchronously in the order of
+// addition.
+func AddSyncHandler(handler func(Err, ErrOcc)) {
+	defaultNotifier.AddSyncHandler(handler)
+}
+
+// AddAsyncHandler is the function that adds an Err creation event handler.
+// Handlers added with this method are executed asynchronously.
+func AddAsyncHandler(handler func(Err, ErrOcc)) {
+	defaultNotifier.AddAsyncHandler(handler)
+}
+
+// EnableCallerInfo is the function to make ErrOcc(s) have the full path of
+// the source file, the function, and the package where an Err occured.
+// This function is ignored after FixCfg function is called.
+func EnableCallerInfo() {
+	defaultNotifier.EnableCallerInfo()
+}
+
+// EnableStackTrace is the function to make ErrOcc(s) have a stack trace of
+// which the maximum number of frames is the argument depth.
+// This function is ignored after FixCfg function is called.
+func EnableStackTrace(depth int) {
+	defaultNotifier.EnableStackTrace(depth)
+}
+
 // FixCfg is the function to fix the configuration of error processing.
 // After calling this function, handlers cannot be added any more and the
 // notification becomes effective.
 func FixCfg() {
-	isErrCfgFixed = true
+	defaultNotifier.FixCfg()
 }
 
 func notifyErr(err Err) {
-	if !isErrCfgFixed {
-		return
-	}
+	activeNotifiersMutex.RLock()
+	notifiers := activeNotifiers
+	activeNotifiersMutex.RUnlock()
 
-	if syncErrHandlers.head == nil && asyncErrHandlers.head == nil {
+	hasHandlers := false
+	callerInfo := false
+	stackDepth := 0
+	collectCfg := func(n *Notifier) {
+		if n.hasHandlers() {
+			hasHandlers = true
+			callerInfo = callerInfo || n.callerInfo
+			if n.stackDepth > stackDepth {
+				stackDepth = n.stackDepth
+			}
+		}
+	}
+	collectCfg(&defaultNotifier)
+	for _, n := range notifiers {
+		collectCfg(n)
+	}
+	if !hasHandlers {
 		return
 	}
 
 	var occ ErrOcc
 	occ.time = time.Now()
 
-	_, file, line, ok := runtime.Caller(2)
-	if ok {
-		occ.file = filepath.Base(file)
-		occ.line = line
+	if !callerInfo && stackDepth == 0 {
+		_, file, line, ok := runtime.Caller(2)
+		if ok {
+			occ.file = filepath.Base(file)
+			occ.line = line
+		}
+	} else {
+		captureCaller(&occ, callerInfo, stackDepth)
 	}
 
-	for el := syncErrHandlers.head; el != nil; el = el.next {
-		el.handler(err, occ)
+	if defaultNotifier.hasHandlers() {
+		defaultNotifier.notify(err, occ)
 	}
+	for _, n := range notifiers {
+		if n.hasHandlers() {
+			n.notify(err, occ)
+		}
+	}
+}
 
-	for el := asyncErrHandlers.head; el != nil; el = el.next {
-		go el.handler(err, occ)
+// captureCaller sets the position, the function, and the stack trace of the
+// caller of New function to the argument ErrOcc.
+func captureCaller(occ *ErrOcc, callerInfo bool, stackDepth int) {
+	n := stackDepth
+	if n < 1 {
+		n = 1
+	}
+	pcs := make([]uintptr, n)
+	// Skips runtime.Callers, captureCaller, notifyErr, and New.
+	n = runtime.Callers(4, pcs)
+	if n == 0 {
+		return
+	}
+
+	var stack []StackFrame
+	frames := runtime.CallersFrames(pcs[:n])
+	for i := 0; ; i++ {
+		frame, more := frames.Next()
+		if i == 0 {
+			occ.file = filepath.Base(frame.File)
+			occ.line = frame.Line
+			if callerInfo {
+				occ.path = frame.File
+				occ.pkg, occ.function = splitFuncName(frame.Function)
+			}
+		}
+		if stackDepth > 0 {
+			stack = append(stack, StackFrame{
+				Function: frame.Function,
+				File:     frame.File,
+				Line:     frame.Line,
+			})
+		}
+		if !more || len(stack) >= stackDepth {
+			break
+		}
+	}
+
+	if len(stack) > 0 {
+		occ.stack = &stack
+	}
+}
+
+// splitFuncName splits a package-qualified function name like
+// "github.com/foo/bar.(*Baz).Qux" into the package path and the function name.
+func splitFuncName(name string) (string, string) {
+	i := strings.LastIndex(name, "/")
+	j := strings.Index(name[i+1:], ".")
+	if j < 0 {
+		return "", name
 	}
+	j += i + 1
+	return name[:j], name[j+1:]
 }
@@ -11,11 +11,11 @@ import (
 )
 
 func ClearErrHandlers() {
-	syncErrHandlers.head = nil
-	syncErrHandlers.last = nil
-	asyncErrHandlers.head = nil
-	asyncErrHandlers.last = nil
-	isErrCfgFixed = false
+	defaultNotifier.syncHandlers.head = nil
+	defaultNotifier.syncHandlers.last = nil
+	defaultNotifier.asyncHandlers.head = nil
+	defaultNotifier.asyncHandlers.last = nil
+	defaultNotifier.isFixed = false
 }
 
 func TestAddSyncHandler_oneHandler(t *testing.T) {
@@ -24,15 +24,15 @@ func TestAddSyncHandler_oneHandler(t *testing.T) {
 
 	AddSyncHandler(func(e Err, o ErrOcc) {})
 
-	assert.NotNil(t, syncErrHandlers.head)
-	assert.NotNil(t, syncErrHandlers.last)
-	assert.Equal(t, syncErrHandlers.head, syncErrHandlers.last)
+	assert.NotNil(t, defaultNotifier.syncHandlers.head)
+	assert.NotNil(t, defaultNotifier.syncHandlers.last)
+	assert.Equal(t, defaultNotifier.syncHandlers.head, defaultNotifier.syncHandlers.last)
 
-	assert.Nil(t, syncErrHandlers.last.next)
-	assert.Nil(t, syncErrHandlers.head.next)
+	assert.Nil(t, defaultNotifier.syncHandlers.last.next)
+	assert.Nil(t, defaultNotifier.syncHandlers.head.next)
 
-	assert.NotNil(t, syncErrHandlers.head.handler)
-	assert.Equal(t, reflect.TypeOf(syncErrHandlers.head.handler).String(), "func(errs.Err, errs.ErrOcc)")
+	assert.NotNil(t, defaultNotifier.syncHandlers.head.handler)
+	assert.Equal(t, reflect.TypeOf(defaultNotifier.syncHandlers.head.handler).String(), "func(errs.Err, errs.ErrOcc)")
 }
 
 func TestAddSyncHandler_twoHandler(t *testing.T) {
@@ -42,18 +42,18 @@ func TestAddSyncHandler_twoHandler(t *testing.T) {
 	AddSyncHandler(func(e Err, o ErrOcc) {})
 	AddSyncHandler(func(e Err, o ErrOcc) {})
 
-	assert.NotNil(t, syncErrHandlers.head)
-	assert.NotNil(t, syncErrHandlers.last)
-	assert.NotEqual(t, syncErrHandlers.head, syncErrHandlers.last)
+	assert.NotNil(t, defaultNotifier.syncHandlers.head)
+	assert.NotNil(t, defaultNotifier.syncHandlers.last)
+	assert.NotEqual(t, defaultNotifier.syncHandlers.head, defaultNotifier.syncHandlers.last)
 
-	assert.Equal(t, syncErrHandlers.head.next, syncErrHandlers.last)
-	assert.Nil(t, syncErrHandlers.last.next)
+	assert.Equal(t, defaultNotifier.syncHandlers.head.next, defaultNotifier.syncHandlers.last)
+	assert.Nil(t, defaultNotifier.syncHandlers.last.next)
 
-	assert.NotNil(t, syncErrHandlers.head.handler)
-	assert.Equal(t, reflect.TypeOf(syncErrHandlers.head.handler).String(), "func(errs.Err, errs.ErrOcc)")
+	assert.NotNil(t, defaultNotifier.syncHandlers.head.handler)
+	assert.Equal(t, reflect.TypeOf(defaultNotifier.syncHandlers.head.handler).String(), "func(errs.Err, errs.ErrOcc)")
 
-	assert.NotNil(t, syncErrHandlers.head.next.handler)
-	assert.Equal(t, reflect.TypeOf(syncErrHandlers.head.next.handler).String(), "func(errs.Err, errs.ErrOcc)")
+	assert.NotNil(t, defaultNotifier.syncHandlers.head.next.handler)
+	assert.Equal(t, reflect.TypeOf(defaultNotifier.syncHandlers.head.next.handler).String(), "func(errs.Err, errs.ErrOcc)")
 }
 
 func TestAddAsyncHandler_oneHandler(t *testing.T) {
@@ -62,15 +62,15 @@ func TestAddAsyncHandler_oneHandler(t *testing.T) {
 
 	AddAsyncHandler(func(e Err, o ErrOcc) {})
 
-	assert.NotNil(t, asyncErrHandlers.head)
-	assert.NotNil(t, asyncErrHandlers.last)
-	assert.Equal(t, asyncErrHandlers.head, asyncErrHandlers.last)
+	assert.NotNil(t, defaultNotifier.asyncHandlers.head)
+	assert.NotNil(t, defaultNotifier.asyncHandlers.last)
+	assert.Equal(t, defaultNotifier.asyncHandlers.head, defaultNotifier.asyncHandlers.last)
 
-	assert.Nil(t, asyncErrHandlers.last.next)
-	assert.Nil(t, asyncErrHandlers.head.next)
+	assert.Nil(t, defaultNotifier.asyncHandlers.last.next)
+	assert.Nil(t, defaultNotifier.asyncHandlers.head.next)
 
-	assert.NotNil(t, asyncErrHandlers.head.handler)
-	assert.Equal(t, reflect.TypeOf(asyncErrHandlers.head.handler).String(), "func(errs.Err, errs.ErrOcc)")
+	assert.NotNil(t, defaultNotifier.asyncHandlers.head.handler)
+	assert.Equal(t, reflect.TypeOf(defaultNotifier.asyncHandlers.head.handler).String(), "func(errs.Err, errs.ErrOcc)")
 }
 
 func TestAddAsyncHandler_twoHandler(t *testing.T) {
@@ -80,18 +80,18 @@ func TestAddAsyncHandler_twoHandler(t *testing.T) {
 	AddAsyncHandler(func(e Err, o ErrOcc) {})
 	AddAsyncHandler(func(e Err, o ErrOcc) {})
 
-	assert.NotNil(t, asyncErrHandlers.head)
-	assert.NotNil(t, asyncErrHandlers.last)
-	assert.NotEqual(t, asyncErrHandlers.head, asyncErrHandlers.last)
+	assert.NotNil(t, defaultNotifier.asyncHandlers.head)
+	assert.NotNil(t, defaultNotifier.asyncHandlers.last)
+	assert.NotEqual(t, defaultNotifier.asyncHandlers.head, defaultNotifier.asyncHandlers.last)
 
-	assert.Equal(t, asyncErrHandlers.head.next, asyncErrHandlers.last)
-	assert.Nil(t, asyncErrHandlers.last.next)
+	assert.Equal(t, defaultNotifier.asyncHandlers.head.next, defaultNotifier.asyncHandlers.last)
+	assert.Nil(t, defaultNotifier.asyncHandlers.last.next)
 
-	assert.NotNil(t, asyncErrHandlers.head.handler)
-	assert.Equal(t, reflect.TypeOf(asyncErrHandlers.head.handler).String(), "func(errs.Err, errs.ErrOcc)")
+	assert.NotNil(t, defaultNotifier.asyncHandlers.head.handler)
+	assert.Equal(t, reflect.TypeOf(defaultNotifier.asyncHandlers.head.handler).String(), "func(errs.Err, errs.ErrOcc)")
 
-	assert.NotNil(t, asyncErrHandlers.head.next.handler)
-	assert.Equal(t, reflect.TypeOf(asyncErrHandlers.head.next.handler).String(), "func(errs.Err, errs.ErrOcc)")
+	assert.NotNil(t, defaultNotifier.asyncHandlers.head.next.handler)
+	assert.Equal(t, reflect.TypeOf(defaultNotifier.asyncHandlers.head.next.handler).String(), "func(errs.Err, errs.ErrOcc)")
 }
 
 func TestFixCfg(t *testing.T) {
@@ -101,42 +101,42 @@ func TestFixCfg(t *testing.T) {
 	AddSyncHandler(func(err Err, occ ErrOcc) {})
 	AddAsyncHandler(func(err Err, occ ErrOcc) {})
 
-	assert.NotNil(t, syncErrHandlers.head)
-	assert.NotNil(t, syncErrHandlers.last)
-	assert.Equal(t, syncErrHandlers.head, syncErrHandlers.last)
-	assert.NotNil(t, syncErrHandlers.head.handler)
-	assert.Nil(t, syncErrHandlers.head.next)
-	assert.Nil(t, syncErrHandlers.last.next)
+	assert.NotNil(t, defaultNotifier.syncHandlers.head)
+	assert.NotNil(t, defaultNotifier.syncHandlers.last)
+	assert.Equal(t, defaultNotifier.syncHandlers.head, defaultNotifier.syncHandlers.last)
+	assert.NotNil(t, defaultNotifier.syncHandlers.head.handler)
+	assert.Nil(t, defaultNotifier.syncHandlers.head.next)
+	assert.Nil(t, defaultNotifier.syncHandlers.last.next)
 
-	assert.NotNil(t, asyncErrHandlers.head)
-	assert.NotNil(t, asyncErrHandlers.last)
-	assert.Equal(t, asyncErrHandlers.head, asyncErrHandlers.last)
-	assert.NotNil(t, asyncErrHandlers.head.handler)
-	assert.Nil(t, asyncErrHandlers.head.next)
-	assert.Nil(t, asyncErrHandlers.last.next)
+	assert.NotNil(t, defaultNotifier.asyncHandlers.head)
+	assert.NotNil(t, defaultNotifier.asyncHandlers.last)
+	assert.Equal(t, defaultNotifier.asyncHandlers.head, defaultNotifier.asyncHandlers.last)
+	assert.NotNil(t, defaultNotifier.asyncHandlers.head.handler)
+	assert.Nil(t, defaultNotifier.asyncHandlers.head.next)
+	assert.Nil(t, defaultNotifier.asyncHandlers.last.next)
 
-	assert.False(t, isErrCfgFixed)
+	assert.False(t, defaultNotifier.isFixed)
 
 	FixCfg()
 
-	assert.True(t, isErrCfgFixed)
+	assert.True(t, defaultNotifier.isFixed)
 
 	AddSyncHandler(func(err Err, occ ErrOcc) {})
 	AddAsyncHandler(func(err Err, occ ErrOcc) {})
 
-	assert.NotNil(t, syncErrHandlers.head)
-	assert.NotNil(t, syncErrHandlers.last)
-	assert.Equal(t, syncErrHandlers.head, syncErrHandlers.last)
-	assert.NotNil(t, syncErrHandlers.head.handler)
-	assert.Nil(t, syncErrHandlers.head.next)
-	assert.Nil(t, syncErrHandlers.last.next)
-
-	assert.NotNil(t, asyncErrHandlers.head)
-	assert.NotNil(t, asyncErrHandlers.last)
-	assert.Equal(t, asyncErrHandlers.head, asyncErrHandlers.last)
-	assert.NotNil(t, asyncErrHandlers.head.handler)
-	assert.Nil(t, asyncErrHandlers.head.next)
-	assert.Nil(t, asyncErrHandlers.last.next)
+	assert.NotNil(t, defaultNotifier.syncHandlers.head)
+	assert.NotNil(t, defaultNotifier.syncHandlers.last)
+	assert.Equal(t, defaultNotifier.syncHandlers.head, defaultNotifier.syncHandlers.last)
+	assert.NotNil(t, defaultNotifier.syncHandlers.head.handler)
+	assert.Nil(t, defaultNotifier.syncHandlers.head.next)
+	assert.Nil(t, defaultNotifier.syncHandlers.last.next)
+
+	assert.NotNil(t, defaultNotifier.asyncHandlers.head)
+	assert.NotNil(t, defaultNotifier.asyncHandlers.last)
+	assert.Equal(t, defaultNotifier.asyncHandlers.head, defaultNotifier.asyncHandlers.last)
+	assert.NotNil(t, defaultNotifier.asyncHandlers.head.handler)
+	assert.Nil(t, defaultNotifier.asyncHandlers.head.next)
+	assert.Nil(t, defaultNotifier.asyncHandlers.last.next)
 }
 
 func TestNotifyErr_withNoErrHandler(t *testing.T) {
@@ -147,11 +147,11 @@ func TestNotifyErr_withNoErrHandler(t *testing.T) {
 
 	New(ReasonForNotification{})
 
-	assert.False(t, isErrCfgFixed)
+	assert.False(t, defaultNotifier.isFixed)
 
 	FixCfg()
 
-	assert.True(t, isErrCfgFixed)
+	assert.True(t, defaultNotifier.isFixed)
 
 	New(ReasonForNotification{})
 }
@@ -184,7 +184,7 @@ func TestNotifyErr_withErrHandler(t *testing.T) {
 			e.ReasonName(), o.File(), o.Line(), o.Time().String()))
 	})
 
-	assert.False(t, isErrCfgFixed)
+	assert.False(t, defaultNotifier.isFixed)
 
 	New(ReasonForNotification{})
 
@@ -193,7 +193,7 @@ func TestNotifyErr_withErrHandler(t *testing.T) {
 
 	FixCfg()
 
-	assert.True(t, isErrCfgFixed)
+	assert.True(t, defaultNotifier.isFixed)
 
 	New(ReasonForNotification{})
 
@@ -215,3 +215,157 @@ func TestNotifyErr_withErrHandler(t *testing.T) {
 	log = log.Next()
 	assert.Nil(t, log)
 }
+
+func TestNotifier_independentConfigurations(t *testing.T) {
+	ClearErrHandlers()
+	defer ClearErrHandlers()
+
+	logs := list.New()
+
+	AddSyncHandler(func(e Err, o ErrOcc) {
+		logs.PushBack("default: " + e.ReasonName())
+	})
+	FixCfg()
+
+	n := NewNotifier()
+	n.AddSyncHandler(func(e Err, o ErrOcc) {
+		logs.PushBack("n: " + e.ReasonName() + " at " + o.File())
+	})
+
+	type FailToDoSomething struct{}
+
+	New(FailToDoSomething{})
+	assert.Equal(t, logs.Len(), 1)
+	assert.Equal(t, logs.Back().Value, "default: FailToDoSomething")
+
+	n.FixCfg()
+	n.AddSyncHandler(func(e Err, o ErrOcc) {
+		logs.PushBack("not added")
+	})
+
+	New(FailToDoSomething{})
+	assert.Equal(t, logs.Len(), 3)
+	assert.Equal(t, logs.Back().Prev().Value, "default: FailToDoSomething")
+	assert.Equal(t, logs.Back().Value, "n: FailToDoSomething at notify_test.go")
+
+	n.Reset()
+	assert.False(t, n.isFixed)
+	assert.Nil(t, n.syncHandlers.head)
+
+	New(FailToDoSomething{})
+	assert.Equal(t, logs.Len(), 4)
+	assert.Equal(t, logs.Back().Value, "default: FailToDoSomething")
+
+	DefaultNotifier().Reset()
+	assert.False(t, defaultNotifier.isFixed)
+	assert.Nil(t, defaultNotifier.syncHandlers.head)
+
+	New(FailToDoSomething{})
+	assert.Equal(t, logs.Len(), 4)
+}
+
+type occTester struct{}
+
+func (t *occTester) newErr() Err {
+	type FailToDoSomething struct{}
+	return New(FailToDoSomething{})
+}
+
+func TestNotifyErr_callerInfoIsDisabledByDefault(t *testing.T) {
+	ClearErrHandlers()
+	defer ClearErrHandlers()
+
+	var occ ErrOcc
+	AddSyncHandler(func(e Err, o ErrOcc) { occ = o })
+	FixCfg()
+
+	(&occTester{}).newErr()
+
+	assert.Equal(t, occ.File(), "notify_test.go")
+	assert.True(t, occ.Line() > 0)
+	assert.Equal(t, occ.Path(), "")
+	assert.Equal(t, occ.Func(), "")
+	assert.Equal(t, occ.Package(), "")
+	assert.Nil(t, occ.Stack())
+}
+
+func TestNotifyErr_withCallerInfo(t *testing.T) {
+	ClearErrHandlers()
+	defer ClearErrHandlers()
+	defer DefaultNotifier().Reset()
+
+	var occ ErrOcc
+	AddSyncHandler(func(e Err, o ErrOcc) { occ = o })
+	EnableCallerInfo()
+	FixCfg()
+
+	EnableStackTrace(10)
+	assert.Equal(t, defaultNotifier.stackDepth, 0)
+
+	(&occTester{}).newErr()
+
+	assert.Equal(t, occ.File(), "notify_test.go")
+	assert.True(t, occ.Line() > 0)
+	assert.Contains(t, occ.Path(), "/errs/notify_test.go")
+	assert.Equal(t, occ.Func(), "(*occTester).newErr")
+	assert.Equal(t, occ.Package(), "github.com/sttk/sabi/errs")
+	assert.Nil(t, occ.Stack())
+}
+
+func TestNotifyErr_withStackTrace(t *testing.T) {
+	ClearErrHandlers()
+	defer ClearErrHandlers()
+	defer DefaultNotifier().Reset()
+
+	var occ ErrOcc
+	AddSyncHandler(func(e Err, o ErrOcc) { occ = o })
+	EnableStackTrace(2)
+	FixCfg()
+
+	(&occTester{}).newErr()
+
+	assert.Equal(t, occ.File(), "notify_test.go")
+	assert.Equal(t, occ.Path(), "")
+	assert.Equal(t, occ.Func(), "")
+
+	stack := occ.Stack()
+	assert.Equal(t, len(stack), 2)
+	assert.Equal(t, stack[0].Function, "github.com/sttk/sabi/errs.(*occTester).newErr")
+	assert.Contains(t, stack[0].File, "/errs/notify_test.go")
+	assert.Equal(t, stack[0].Line, occ.Line())
+	assert.Equal(t, stack[1].Function, "github.com/sttk/sabi/errs.TestNotifyErr_withStackTrace")
+
+	stack[0].Line = 0
+	assert.Equal(t, occ.Stack()[0].Line, occ.Line())
+
+	occ2 := occ
+	assert.True(t, occ2 == occ)
+
+	DefaultNotifier().Reset()
+	assert.Equal(t, defaultNotifier.stackDepth, 0)
+	assert.False(t, defaultNotifier.callerInfo)
+}
+
+func TestNotifier_EnableStackTrace_negativeDepth(t *testing.T) {
+	n := NewNotifier()
+	n.EnableStackTrace(-1)
+	assert.Equal(t, n.stackDepth, 0)
+}
+
+func TestSplitFuncName(t *testing.T) {
+	pkg, fn := splitFuncName("github.com/foo/bar.(*Baz).Qux")
+	assert.Equal(t, pkg, "github.com/foo/bar")
+	assert.Equal(t, fn, "(*Baz).Qux")
+
+	pkg, fn = splitFuncName("main.main.func1")
+	assert.Equal(t, pkg, "main")
+	assert.Equal(t, fn, "main.func1")
+
+	pkg, fn = splitFuncName("github.com/foo/bar.v2.Qux")
+	assert.Equal(t, pkg, "github.com/foo/bar")
+	assert.Equal(t, fn, "v2.Qux")
+
+	pkg, fn = splitFuncName("noDot")
+	assert.Equal(t, pkg, "")
+	assert.Equal(t, fn, "noDot")
+}
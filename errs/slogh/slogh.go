@@ -0,0 +1,311 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+//go:build go1.21
+
+// slogh is the package that provides an Err creation event handler which logs
+// Err(s) as structured records with log/slog.
+//
+// A record has the following attributes:
+//   - reason: the name of the reason struct type,
+//   - package: the package path of the reason struct type,
+//   - situation: a group of fields of reasons in the cause chain,
+//   - cause: a group of the causal error, which is nested for each Err in
+//     the cause chain,
+//   - causes: instead of cause, a group of groups of the causal errors keyed
+//     by their indexes, if an Err has multiple causes,
+//   - file and line: the position where the Err occured.
+//   - path, func, and func_package: the full path of the source file, the
+//     function, and its package where the Err occured, if they are enabled
+//     with errs.EnableCallerInfo,
+//   - stack: the stack trace as a list of "function file:line", if it is
+//     enabled with errs.EnableStackTrace.
+//
+// The time of a record is the time when the Err occured.
+//
+//	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+//	errs.AddAsyncHandler(slogh.New(logger).
+//	    WithReasonLevel(NotFound{}, slog.LevelInfo).
+//	    WithRateLimit(FailToConnect{}, 10, time.Minute).
+//	    Handle)
+//	errs.FixCfg()
+package slogh
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sttk/sabi/errs"
+)
+
+// DefaultMessage is the message of records which Handler logs by default.
+const DefaultMessage = "error occurred"
+
+// Handler is the struct type that logs Err(s) with a *slog.Logger.
+// Handle method of this struct type is registered as an Err creation event
+// handler with errs.AddSyncHandler or errs.AddAsyncHandler.
+// The level of a record is slog.LevelError by default, and can be changed for
+// each reason type.
+// Records of noisy reasons can be thinned out by sampling or rate limiting.
+//
+// Handler is configured with its With... methods before being registered, and
+// Handle method is safe for concurrent use.
+type Handler struct {
+	logger       *slog.Logger
+	msg          string
+	defaultLevel slog.Level
+	levels       map[reflect.Type]slog.Level
+	limiters     map[reflect.Type]limiter
+	mutex        sync.Mutex
+	now          func() time.Time
+}
+
+// New is the function that creates a new Handler instance which logs Err(s)
+// with the argument *slog.Logger.
+// If the argument is nil, slog.Default() is used.
+func New(logger *slog.Logger) *Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Handler{
+		logger:       logger,
+		msg:          DefaultMessage,
+		defaultLevel: slog.LevelError,
+		levels:       make(map[reflect.Type]slog.Level),
+		limiters:     make(map[reflect.Type]limiter),
+		now:          time.Now,
+	}
+}
+
+// WithMessage is the method to set the message of records.
+// This method returns this Handler itself.
+func (h *Handler) WithMessage(msg string) *Handler {
+	h.msg = msg
+	return h
+}
+
+// WithLevel is the method to set the level of records of which reasons are
+// not specified with WithReasonLevel method.
+// This method returns this Handler itself.
+func (h *Handler) WithLevel(level slog.Level) *Handler {
+	h.defaultLevel = level
+	return h
+}
+
+// WithReasonLevel is the method to set the level of records of Err(s) which
+// have the same reason type as the argument reason.
+// The argument reason is a value or a pointer of a reason struct type, and
+// both forms are regarded as the same reason type.
+// This method returns this Handler itself.
+func (h *Handler) WithReasonLevel(reason any, level slog.Level) *Handler {
+	h.levels[reasonType(reason)] = level
+	return h
+}
+
+// WithSampling is the method to log only one of every n Err(s) which have the
+// same reason type as the argument reason.
+// The first Err is logged, and the number of Err(s) suppressed before a
+// logged one is added to the record as the attribute: suppressed.
+// This method returns this Handler itself.
+func (h *Handler) WithSampling(reason any, n int) *Handler {
+	if n > 1 {
+		h.limiters[reasonType(reason)] = &sampler{every: n}
+	}
+	return h
+}
+
+// WithRateLimit is the method to log at most n Err(s) per the argument
+// interval for Err(s) which have the same reason type as the argument
+// reason.
+// The number of Err(s) suppressed before a logged one is added to the record
+// as the attribute: suppressed.
+// This method returns this Handler itself.
+func (h *Handler) WithRateLimit(reason any, n int, interval time.Duration) *Handler {
+	if n > 0 && interval > 0 {
+		h.limiters[reasonType(reason)] = &rateLimiter{limit: n, interval: interval}
+	}
+	return h
+}
+
+// Handle is the method to log the argument Err.
+// This method is registered as an Err creation event handler.
+func (h *Handler) Handle(err errs.Err, occ errs.ErrOcc) {
+	t := reasonType(err.Reason())
+
+	level, exists := h.levels[t]
+	if !exists {
+		level = h.defaultLevel
+	}
+
+	ctx := context.Background()
+	if !h.logger.Enabled(ctx, level) {
+		return
+	}
+
+	suppressed := 0
+	if l, exists := h.limiters[t]; exists {
+		h.mutex.Lock()
+		ok, n := l.allow(h.now())
+		h.mutex.Unlock()
+		if !ok {
+			return
+		}
+		suppressed = n
+	}
+
+	r := slog.NewRecord(occ.Time(), level, h.msg, 0)
+	r.AddAttrs(Attrs(err, occ)...)
+	if suppressed > 0 {
+		r.AddAttrs(slog.Int("suppressed", suppressed))
+	}
+	h.logger.Handler().Handle(ctx, r)
+}
+
+// Attrs is the function to convert the argument Err and ErrOcc to slog
+// attributes.
+// This function is used to build records in Handler, and is also useful to
+// implement a custom handler.
+func Attrs(err errs.Err, occ errs.ErrOcc) []slog.Attr {
+	attrs := errAttrs(err)
+	attrs = append(attrs,
+		slog.String("file", occ.File()),
+		slog.Int("line", occ.Line()),
+	)
+	if occ.Path() != "" {
+		attrs = append(attrs,
+			slog.String("path", occ.Path()),
+			slog.String("func", occ.Func()),
+			slog.String("func_package", occ.Package()),
+		)
+	}
+	if stack := occ.Stack(); len(stack) > 0 {
+		frames := make([]string, len(stack))
+		for i, f := range stack {
+			frames[i] = f.Function + " " + f.File + ":" + strconv.Itoa(f.Line)
+		}
+		attrs = append(attrs, slog.Any("stack", frames))
+	}
+	return attrs
+}
+
+func errAttrs(err errs.Err) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("reason", err.ReasonName()),
+		slog.String("package", err.ReasonPackage()),
+	}
+
+	situation := err.Situation()
+	if len(situation) > 0 {
+		keys := make([]string, 0, len(situation))
+		for k := range situation {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		args := make([]any, 0, len(keys))
+		for _, k := range keys {
+			args = append(args, slog.Any(k, situation[k]))
+		}
+		attrs = append(attrs, slog.Group("situation", args...))
+	}
+
+	if a, ok := causesAttr(err); ok {
+		attrs = append(attrs, a)
+	}
+
+	return attrs
+}
+
+// causesAttr converts the causes of the argument Err to a "cause" group if
+// it has one cause, or to a "causes" group keyed by the indexes of the causes
+// if it has multiple causes.
+func causesAttr(err errs.Err) (slog.Attr, bool) {
+	causes := err.Causes()
+	switch len(causes) {
+	case 0:
+		return slog.Attr{}, false
+	case 1:
+		return causeAttr("cause", causes[0]), true
+	}
+	args := make([]any, len(causes))
+	for i, c := range causes {
+		args[i] = causeAttr(strconv.Itoa(i), c)
+	}
+	return slog.Group("causes", args...), true
+}
+
+func causeAttr(key string, cause error) slog.Attr {
+	var args []any
+	if e, ok := cause.(errs.Err); ok {
+		args = append(args,
+			slog.String("reason", e.ReasonName()),
+			slog.String("package", e.ReasonPackage()),
+		)
+		if a, ok := causesAttr(e); ok {
+			args = append(args, a)
+		}
+	} else {
+		args = append(args, slog.String("error", cause.Error()))
+	}
+	return slog.Group(key, args...)
+}
+
+func reasonType(reason any) reflect.Type {
+	t := reflect.TypeOf(reason)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// limiter is the interface to decide whether a record is logged, and to
+// count records suppressed since the last logged one.
+type limiter interface {
+	allow(now time.Time) (bool, int)
+}
+
+type sampler struct {
+	every      int
+	count      int
+	suppressed int
+}
+
+func (s *sampler) allow(now time.Time) (bool, int) {
+	s.count++
+	if (s.count-1)%s.every != 0 {
+		s.suppressed++
+		return false, 0
+	}
+	n := s.suppressed
+	s.suppressed = 0
+	return true, n
+}
+
+type rateLimiter struct {
+	limit       int
+	interval    time.Duration
+	windowStart time.Time
+	count       int
+	suppressed  int
+}
+
+func (l *rateLimiter) allow(now time.Time) (bool, int) {
+	if l.windowStart.IsZero() || now.Sub(l.windowStart) >= l.interval {
+		l.windowStart = now
+		l.count = 0
+	}
+	if l.count >= l.limit {
+		l.suppressed++
+		return false, 0
+	}
+	l.count++
+	n := l.suppressed
+	l.suppressed = 0
+	return true, n
+}
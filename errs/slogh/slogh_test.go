@@ -0,0 +1,238 @@
+//go:build go1.21
+
+package slogh
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type (
+	FailToDoSomething struct {
+		Name string
+	}
+	FailToConnect struct {
+		Host string
+		Port int
+	}
+	NotFound struct{}
+)
+
+type recordHandler struct {
+	mutex   sync.Mutex
+	level   slog.Level
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *recordHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
+func (h *recordHandler) WithGroup(name string) slog.Handler       { return h }
+
+func attrMap(r slog.Record) map[string]any {
+	m := make(map[string]any)
+	r.Attrs(func(a slog.Attr) bool {
+		m[a.Key] = flatten(a.Value)
+		return true
+	})
+	return m
+}
+
+func flatten(v slog.Value) any {
+	if v.Kind() != slog.KindGroup {
+		return v.Any()
+	}
+	m := make(map[string]any)
+	for _, a := range v.Group() {
+		m[a.Key] = flatten(a.Value)
+	}
+	return m
+}
+
+// notify creates Errs with the argument function, and passes them and their
+// ErrOcc(s) to the argument handler through a Notifier.
+func notify(handler func(errs.Err, errs.ErrOcc), fn func()) {
+	n := errs.NewNotifier()
+	n.AddSyncHandler(handler)
+	n.FixCfg()
+	defer n.Reset()
+	fn()
+}
+
+func TestHandler_Handle(t *testing.T) {
+	rh := &recordHandler{level: slog.LevelDebug}
+	h := New(slog.New(rh))
+
+	var err errs.Err
+	notify(h.Handle, func() {
+		cause := errs.New(FailToConnect{Host: "localhost", Port: 5432}, errors.New("refused"))
+		err = errs.New(FailToDoSomething{Name: "foo"}, cause)
+	})
+
+	assert.Equal(t, len(rh.records), 2)
+
+	r := rh.records[1]
+	assert.Equal(t, r.Level, slog.LevelError)
+	assert.Equal(t, r.Message, DefaultMessage)
+	assert.False(t, r.Time.IsZero())
+
+	m := attrMap(r)
+	assert.Equal(t, m["reason"], "FailToDoSomething")
+	assert.Equal(t, m["package"], "github.com/sttk/sabi/errs/slogh")
+	assert.Equal(t, m["situation"], map[string]any{
+		"Name": "foo",
+		"Host": "localhost",
+		"Port": int64(5432),
+	})
+	assert.Equal(t, m["cause"], map[string]any{
+		"reason":  "FailToConnect",
+		"package": "github.com/sttk/sabi/errs/slogh",
+		"cause":   map[string]any{"error": "refused"},
+	})
+	assert.Equal(t, m["file"], "slogh_test.go")
+	assert.True(t, m["line"].(int64) > 0)
+	_, exists := m["suppressed"]
+	assert.False(t, exists)
+
+	assert.Equal(t, err.ReasonName(), "FailToDoSomething")
+}
+
+func TestAttrs_multipleCauses(t *testing.T) {
+	var occ errs.ErrOcc
+	var err errs.Err
+	notify(func(e errs.Err, o errs.ErrOcc) { occ = o }, func() {
+		err = errs.New(FailToDoSomething{Name: "foo"},
+			errs.New(FailToConnect{Host: "localhost"}, errors.New("refused")),
+			errors.New("timeout"))
+	})
+
+	r := slog.NewRecord(time.Now(), slog.LevelError, "", 0)
+	r.AddAttrs(Attrs(err, occ)...)
+	m := attrMap(r)
+	_, exists := m["cause"]
+	assert.False(t, exists)
+	assert.Equal(t, m["causes"], map[string]any{
+		"0": map[string]any{
+			"reason":  "FailToConnect",
+			"package": "github.com/sttk/sabi/errs/slogh",
+			"cause":   map[string]any{"error": "refused"},
+		},
+		"1": map[string]any{"error": "timeout"},
+	})
+}
+
+func TestHandler_levels(t *testing.T) {
+	rh := &recordHandler{level: slog.LevelInfo}
+	h := New(slog.New(rh)).
+		WithMessage("failed").
+		WithLevel(slog.LevelWarn).
+		WithReasonLevel(&NotFound{}, slog.LevelDebug).
+		WithReasonLevel(FailToConnect{}, slog.LevelError)
+
+	notify(h.Handle, func() {
+		errs.New(NotFound{})
+		errs.New(&FailToConnect{})
+		errs.New(FailToDoSomething{})
+	})
+
+	assert.Equal(t, len(rh.records), 2)
+	assert.Equal(t, rh.records[0].Level, slog.LevelError)
+	assert.Equal(t, rh.records[0].Message, "failed")
+	assert.Equal(t, rh.records[1].Level, slog.LevelWarn)
+	assert.Equal(t, attrMap(rh.records[1])["reason"], "FailToDoSomething")
+}
+
+func TestHandler_WithSampling(t *testing.T) {
+	rh := &recordHandler{}
+	h := New(slog.New(rh)).WithSampling(NotFound{}, 3)
+
+	notify(h.Handle, func() {
+		for i := 0; i < 7; i++ {
+			errs.New(NotFound{})
+		}
+		errs.New(FailToDoSomething{})
+	})
+
+	assert.Equal(t, len(rh.records), 4)
+	_, exists := attrMap(rh.records[0])["suppressed"]
+	assert.False(t, exists)
+	assert.Equal(t, attrMap(rh.records[1])["suppressed"], int64(2))
+	assert.Equal(t, attrMap(rh.records[2])["suppressed"], int64(2))
+	assert.Equal(t, attrMap(rh.records[3])["reason"], "FailToDoSomething")
+}
+
+func TestHandler_WithRateLimit(t *testing.T) {
+	rh := &recordHandler{}
+	h := New(slog.New(rh)).WithRateLimit(&FailToConnect{}, 2, time.Minute)
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	notify(h.Handle, func() {
+		for i := 0; i < 5; i++ {
+			errs.New(FailToConnect{})
+		}
+		now = now.Add(30 * time.Second)
+		errs.New(FailToConnect{})
+		now = now.Add(30 * time.Second)
+		errs.New(FailToConnect{})
+		errs.New(FailToConnect{})
+		errs.New(FailToConnect{})
+	})
+
+	assert.Equal(t, len(rh.records), 4)
+	_, exists := attrMap(rh.records[0])["suppressed"]
+	assert.False(t, exists)
+	_, exists = attrMap(rh.records[1])["suppressed"]
+	assert.False(t, exists)
+	assert.Equal(t, attrMap(rh.records[2])["suppressed"], int64(4))
+	_, exists = attrMap(rh.records[3])["suppressed"]
+	assert.False(t, exists)
+}
+
+func TestAttrs_callerInfoAndStack(t *testing.T) {
+	var occ errs.ErrOcc
+	n := errs.NewNotifier()
+	n.AddSyncHandler(func(e errs.Err, o errs.ErrOcc) { occ = o })
+	n.EnableCallerInfo()
+	n.EnableStackTrace(1)
+	n.FixCfg()
+	defer n.Reset()
+
+	err := errs.New(NotFound{})
+
+	r := slog.NewRecord(time.Now(), slog.LevelError, "", 0)
+	r.AddAttrs(Attrs(err, occ)...)
+	m := attrMap(r)
+	assert.Contains(t, m["path"], "/errs/slogh/slogh_test.go")
+	assert.Equal(t, m["func"], "TestAttrs_callerInfoAndStack")
+	assert.Equal(t, m["func_package"], "github.com/sttk/sabi/errs/slogh")
+
+	stack := m["stack"].([]string)
+	assert.Equal(t, len(stack), 1)
+	assert.Contains(t, stack[0],
+		"github.com/sttk/sabi/errs/slogh.TestAttrs_callerInfoAndStack ")
+	assert.Contains(t, stack[0], "/errs/slogh/slogh_test.go:")
+}
+
+func TestNew_nilLogger(t *testing.T) {
+	h := New(nil)
+	assert.Equal(t, h.logger, slog.Default())
+}
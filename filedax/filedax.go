@@ -0,0 +1,354 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+// filedax is the package that provides a DaxSrc and a DaxConn for files under
+// a directory, with atomic commit semantics.
+//
+// DaxConn stages written files in a temporary directory under the target
+// directory, and renames them into place at Commit.
+// Staged files are deleted at Rollback, and files replaced or removed by a
+// commit are restored from their backups at ForceBack.
+//
+//	sabi.Uses("output", filedax.NewDaxSrc("/var/data/output"))
+//
+//	func (dax OutputDax) WriteReport(report []byte) errs.Err {
+//	    conn, err := sabi.GetDaxConn[*filedax.DaxConn](dax, "output")
+//	    if err.IsNotOk() {
+//	        return err
+//	    }
+//	    return conn.WriteFile("report.txt", report, 0644)
+//	}
+package filedax
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+)
+
+type /* error reasons */ (
+	// FailToCreateDir is the error reason which indicates that it is failed to
+	// create a directory.
+	// The field Path is the path of the directory.
+	FailToCreateDir struct {
+		Path string
+	}
+
+	// InvalidFileName is the error reason which indicates that a file name is
+	// absolute or points outside of the directory of a DaxSrc.
+	// The field Name is the file name.
+	InvalidFileName struct {
+		Name string
+	}
+
+	// FailToStageFile is the error reason which indicates that it is failed to
+	// write a file into the staging directory.
+	// The field Name is the file name.
+	FailToStageFile struct {
+		Name string
+	}
+
+	// FailToReadFile is the error reason which indicates that it is failed to
+	// read a file.
+	// The field Name is the file name.
+	FailToReadFile struct {
+		Name string
+	}
+
+	// FailToCommitFile is the error reason which indicates that it is failed to
+	// move a staged file into place or to remove a file at Commit.
+	// The field Name is the file name.
+	FailToCommitFile struct {
+		Name string
+	}
+)
+
+const stagingPattern = ".filedax-staging-*"
+
+// DaxSrc is the struct type that implements sabi.DaxSrc for files under a
+// directory.
+type DaxSrc struct {
+	dir  string
+	perm fs.FileMode
+}
+
+// NewDaxSrc is the function that creates a new DaxSrc instance for files
+// under the argument directory.
+func NewDaxSrc(dir string) *DaxSrc {
+	return &DaxSrc{dir: dir, perm: 0755}
+}
+
+// WithDirPerm is the method to set the permission of directories which this
+// DaxSrc and its DaxConn(s) create.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithDirPerm(perm fs.FileMode) *DaxSrc {
+	ds.perm = perm
+	return ds
+}
+
+// Setup is the method to create the directory if it does not exist.
+func (ds *DaxSrc) Setup(ag sabi.AsyncGroup) errs.Err {
+	e := os.MkdirAll(ds.dir, ds.perm)
+	if e != nil {
+		return errs.New(FailToCreateDir{Path: ds.dir}, e)
+	}
+	return errs.Ok()
+}
+
+// Close is the method which does nothing.
+func (ds *DaxSrc) Close() {}
+
+// CreateDaxConn is the method to create a DaxConn instance.
+// A staging directory is created when a file is written first in the
+// transaction.
+func (ds *DaxSrc) CreateDaxConn() (sabi.DaxConn, errs.Err) {
+	return &DaxConn{ds: ds, ops: make(map[string]fileOp)}, errs.Ok()
+}
+
+type fileOp struct {
+	staged  string
+	removed bool
+}
+
+type appliedOp struct {
+	name   string
+	backup string
+}
+
+// DaxConn is the struct type that implements sabi.DaxConn for files under a
+// directory.
+type DaxConn struct {
+	ds         *DaxSrc
+	mutex      sync.Mutex
+	stagingDir string
+	ops        map[string]fileOp
+	order      []string
+	applied    []appliedOp
+	committed  bool
+}
+
+func (conn *DaxConn) targetPath(name string) (string, errs.Err) {
+	clean := filepath.Clean(name)
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errs.New(InvalidFileName{Name: name})
+	}
+	return filepath.Join(conn.ds.dir, clean), errs.Ok()
+}
+
+func (conn *DaxConn) staging() (string, error) {
+	if conn.stagingDir != "" {
+		return conn.stagingDir, nil
+	}
+	dir, e := os.MkdirTemp(conn.ds.dir, stagingPattern)
+	if e != nil {
+		return "", e
+	}
+	conn.stagingDir = dir
+	return dir, nil
+}
+
+// WriteFile is the method to stage a file with the argument name and data.
+// The argument name is a relative path from the directory of the DaxSrc.
+// The staged file is moved to that path at Commit.
+func (conn *DaxConn) WriteFile(name string, data []byte, perm fs.FileMode) errs.Err {
+	if _, err := conn.targetPath(name); err.IsNotOk() {
+		return err
+	}
+
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	dir, e := conn.staging()
+	if e != nil {
+		return errs.New(FailToStageFile{Name: name}, e)
+	}
+
+	staged := filepath.Join(dir, "files", filepath.Clean(name))
+	e = os.MkdirAll(filepath.Dir(staged), conn.ds.perm)
+	if e == nil {
+		e = os.WriteFile(staged, data, perm)
+	}
+	if e != nil {
+		return errs.New(FailToStageFile{Name: name}, e)
+	}
+
+	conn.put(filepath.Clean(name), fileOp{staged: staged})
+	return errs.Ok()
+}
+
+// Remove is the method to stage a removal of a file with the argument name.
+// The file is removed at Commit.
+func (conn *DaxConn) Remove(name string) errs.Err {
+	if _, err := conn.targetPath(name); err.IsNotOk() {
+		return err
+	}
+
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.put(filepath.Clean(name), fileOp{removed: true})
+	return errs.Ok()
+}
+
+func (conn *DaxConn) put(name string, op fileOp) {
+	if _, exists := conn.ops[name]; !exists {
+		conn.order = append(conn.order, name)
+	}
+	conn.ops[name] = op
+}
+
+// ReadFile is the method to read a file with the argument name.
+// A file written or removed in the current transaction takes precedence over
+// the file in the directory.
+func (conn *DaxConn) ReadFile(name string) ([]byte, errs.Err) {
+	path, err := conn.targetPath(name)
+	if err.IsNotOk() {
+		return nil, err
+	}
+
+	conn.mutex.Lock()
+	op, exists := conn.ops[filepath.Clean(name)]
+	conn.mutex.Unlock()
+
+	if exists {
+		if op.removed {
+			return nil, errs.New(FailToReadFile{Name: name}, fs.ErrNotExist)
+		}
+		path = op.staged
+	}
+
+	data, e := os.ReadFile(path)
+	if e != nil {
+		return nil, errs.New(FailToReadFile{Name: name}, e)
+	}
+	return data, errs.Ok()
+}
+
+// Commit is the method to move staged files into place and to remove files
+// staged for removal.
+// Files replaced or removed are backed up in the staging directory until
+// this DaxConn is closed, so that they can be restored at ForceBack.
+// If this method fails in the middle, files already moved are restored.
+func (conn *DaxConn) Commit(ag sabi.AsyncGroup) errs.Err {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	for _, name := range conn.order {
+		err := conn.apply(name, conn.ops[name])
+		if err.IsNotOk() {
+			conn.restore()
+			return err
+		}
+	}
+
+	conn.ops = make(map[string]fileOp)
+	conn.order = nil
+	conn.committed = true
+	return errs.Ok()
+}
+
+func (conn *DaxConn) apply(name string, op fileOp) errs.Err {
+	target := filepath.Join(conn.ds.dir, name)
+	applied := appliedOp{name: name}
+
+	_, e := os.Lstat(target)
+	if e == nil {
+		dir, e := conn.staging()
+		if e != nil {
+			return errs.New(FailToCommitFile{Name: name}, e)
+		}
+		backup := filepath.Join(dir, "backups", name)
+		e = os.MkdirAll(filepath.Dir(backup), conn.ds.perm)
+		if e == nil {
+			e = os.Rename(target, backup)
+		}
+		if e != nil {
+			return errs.New(FailToCommitFile{Name: name}, e)
+		}
+		applied.backup = backup
+	} else if !errors.Is(e, fs.ErrNotExist) {
+		return errs.New(FailToCommitFile{Name: name}, e)
+	}
+
+	conn.applied = append(conn.applied, applied)
+
+	if op.removed {
+		return errs.Ok()
+	}
+
+	e = os.MkdirAll(filepath.Dir(target), conn.ds.perm)
+	if e == nil {
+		e = os.Rename(op.staged, target)
+	}
+	if e != nil {
+		return errs.New(FailToCommitFile{Name: name}, e)
+	}
+	return errs.Ok()
+}
+
+func (conn *DaxConn) restore() {
+	for i := len(conn.applied) - 1; i >= 0; i-- {
+		a := conn.applied[i]
+		target := filepath.Join(conn.ds.dir, a.name)
+		os.Remove(target)
+		if a.backup != "" {
+			os.Rename(a.backup, target)
+		}
+	}
+	conn.applied = nil
+}
+
+// IsCommitted is the method to check whether staged files are already moved
+// into place.
+func (conn *DaxConn) IsCommitted() bool {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	return conn.committed
+}
+
+// Rollback is the method to delete staged files.
+func (conn *DaxConn) Rollback(ag sabi.AsyncGroup) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	conn.clear()
+}
+
+// ForceBack is the method to delete files moved into place by the commit of
+// this DaxConn, and to restore the original files from their backups.
+// If this DaxConn is not committed, this method deletes staged files.
+func (conn *DaxConn) ForceBack(ag sabi.AsyncGroup) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	if conn.committed {
+		conn.restore()
+		conn.committed = false
+	}
+	conn.clear()
+}
+
+// Close is the method to delete the staging directory including staged files
+// and backups.
+func (conn *DaxConn) Close() {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	conn.clear()
+}
+
+func (conn *DaxConn) clear() {
+	if conn.stagingDir != "" {
+		os.RemoveAll(conn.stagingDir)
+		conn.stagingDir = ""
+	}
+	conn.ops = make(map[string]fileOp)
+	conn.order = nil
+	conn.applied = nil
+}
@@ -0,0 +1,188 @@
+package filedax
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+	"github.com/sttk/sabi/sabitest"
+)
+
+type (
+	FailToCommit struct{}
+	FailToDo     struct{}
+)
+
+func readFile(t *testing.T, path string) string {
+	data, e := os.ReadFile(path)
+	assert.Nil(t, e)
+	return string(data)
+}
+
+func assertNoStagingDir(t *testing.T, dir string) {
+	matches, e := filepath.Glob(filepath.Join(dir, stagingPattern))
+	assert.Nil(t, e)
+	assert.Equal(t, len(matches), 0)
+}
+
+func TestDaxSrc_Setup(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+
+	ds := NewDaxSrc(dir)
+	err := ds.Setup(nil)
+	assert.True(t, err.IsOk())
+
+	info, e := os.Stat(dir)
+	assert.Nil(t, e)
+	assert.True(t, info.IsDir())
+}
+
+func TestDaxConn_invalidFileName(t *testing.T) {
+	ds := NewDaxSrc(t.TempDir())
+	c, _ := ds.CreateDaxConn()
+	conn := c.(*DaxConn)
+
+	for _, name := range []string{"/etc/passwd", "../x", "a/../../x", "."} {
+		err := conn.WriteFile(name, []byte("x"), 0644)
+		switch r := err.Reason().(type) {
+		case InvalidFileName:
+			assert.Equal(t, r.Name, name)
+		default:
+			assert.Fail(t, err.Error())
+		}
+	}
+}
+
+func TestDaxConn_commitAndForceBack(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("old a"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("old b"), 0644))
+
+	ds := NewDaxSrc(dir)
+	c, _ := ds.CreateDaxConn()
+	conn := c.(*DaxConn)
+
+	assert.True(t, conn.WriteFile("a.txt", []byte("new a"), 0644).IsOk())
+	assert.True(t, conn.WriteFile("sub/c.txt", []byte("new c"), 0644).IsOk())
+	assert.True(t, conn.Remove("b.txt").IsOk())
+
+	data, err := conn.ReadFile("a.txt")
+	assert.True(t, err.IsOk())
+	assert.Equal(t, string(data), "new a")
+	_, err = conn.ReadFile("b.txt")
+	assert.IsType(t, err.Reason(), FailToReadFile{})
+
+	assert.Equal(t, readFile(t, filepath.Join(dir, "a.txt")), "old a")
+	_, e := os.Stat(filepath.Join(dir, "sub", "c.txt"))
+	assert.True(t, os.IsNotExist(e))
+
+	assert.True(t, conn.Commit(nil).IsOk())
+	assert.True(t, conn.IsCommitted())
+
+	assert.Equal(t, readFile(t, filepath.Join(dir, "a.txt")), "new a")
+	assert.Equal(t, readFile(t, filepath.Join(dir, "sub", "c.txt")), "new c")
+	_, e = os.Stat(filepath.Join(dir, "b.txt"))
+	assert.True(t, os.IsNotExist(e))
+
+	conn.ForceBack(nil)
+	assert.False(t, conn.IsCommitted())
+
+	assert.Equal(t, readFile(t, filepath.Join(dir, "a.txt")), "old a")
+	assert.Equal(t, readFile(t, filepath.Join(dir, "b.txt")), "old b")
+	_, e = os.Stat(filepath.Join(dir, "sub", "c.txt"))
+	assert.True(t, os.IsNotExist(e))
+
+	conn.Close()
+	assertNoStagingDir(t, dir)
+}
+
+func TestDaxConn_Rollback(t *testing.T) {
+	dir := t.TempDir()
+
+	ds := NewDaxSrc(dir)
+	c, _ := ds.CreateDaxConn()
+	conn := c.(*DaxConn)
+
+	assert.True(t, conn.WriteFile("a.txt", []byte("new a"), 0644).IsOk())
+	conn.Rollback(nil)
+	assertNoStagingDir(t, dir)
+
+	assert.True(t, conn.Commit(nil).IsOk())
+	_, e := os.Stat(filepath.Join(dir, "a.txt"))
+	assert.True(t, os.IsNotExist(e))
+
+	conn.Close()
+}
+
+func TestDaxSrc_Txn(t *testing.T) {
+	dir := t.TempDir()
+
+	reg := sabi.NewRegistry()
+	reg.Uses("output", NewDaxSrc(dir))
+	assert.True(t, reg.Setup().IsOk())
+	defer reg.Close()
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err := sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn](dax, "output")
+		if err.IsNotOk() {
+			return err
+		}
+		return conn.WriteFile("report.txt", []byte("report"), 0644)
+	})
+	assert.True(t, err.IsOk())
+	assert.Equal(t, readFile(t, filepath.Join(dir, "report.txt")), "report")
+	assertNoStagingDir(t, dir)
+
+	err = sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn](dax, "output")
+		if err.IsNotOk() {
+			return err
+		}
+		err = conn.WriteFile("report.txt", []byte("half"), 0644)
+		if err.IsNotOk() {
+			return err
+		}
+		return errs.New(FailToDo{})
+	})
+	assert.IsType(t, err.Reason(), FailToDo{})
+	assert.Equal(t, readFile(t, filepath.Join(dir, "report.txt")), "report")
+	assertNoStagingDir(t, dir)
+}
+
+func TestDaxSrc_Txn_forceBack(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "report.txt"), []byte("old"), 0644))
+
+	reg := sabi.NewRegistry()
+	reg.Uses("output", NewDaxSrc(dir))
+	reg.Uses("database", sabitest.NewDaxSrc("database", nil).
+		WithCommitErr(errs.New(FailToCommit{})))
+	assert.True(t, reg.Setup().IsOk())
+	defer reg.Close()
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err := sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn](dax, "output")
+		if err.IsNotOk() {
+			return err
+		}
+		err = conn.WriteFile("report.txt", []byte("new"), 0644)
+		if err.IsNotOk() {
+			return err
+		}
+		_, err = sabi.GetDaxConn[*sabitest.DaxConn](dax, "database")
+		return err
+	})
+	assert.IsType(t, err.Reason(), sabi.FailToCommitDaxConn{})
+	assert.Equal(t, readFile(t, filepath.Join(dir, "report.txt")), "old")
+	assertNoStagingDir(t, dir)
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+
+	"github.com/sttk/sabi/errs"
+)
+
+// HealthChecker is the optional interface which a DaxSrc implements to report
+// whether it is still healthy after it is set up.
+//
+// HealthCheck is the method to check the health of the data source, and
+// returns an errs.Err having the reason if it is not healthy.
+// The argument context.Context is used to stop checking when it is canceled or
+// its deadline is exceeded.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) errs.Err
+}
+
+// HealthCheck is the function that checks the health of all global DaxSrc(s)
+// implementing HealthChecker concurrently.
+// This function returns a map of which keys are the registered names of those
+// DaxSrc(s) and of which values are the results of their checks.
+// DaxSrc(s) not implementing HealthChecker are not included in the map.
+func HealthCheck() map[string]errs.Err {
+	return defaultRegistry.HealthCheckCtx(context.Background())
+}
+
+// HealthCheckCtx is the function that does the same as HealthCheck function,
+// but passes the argument context.Context to each HealthChecker.
+func HealthCheckCtx(ctx context.Context) map[string]errs.Err {
+	return defaultRegistry.HealthCheckCtx(ctx)
+}
+
+// HealthCheck is the method that checks the health of global DaxSrc(s) of
+// this Registry.
+// See HealthCheck function for details.
+func (reg *Registry) HealthCheck() map[string]errs.Err {
+	return reg.HealthCheckCtx(context.Background())
+}
+
+// HealthCheckCtx is the method that does the same as HealthCheck method, but
+// passes the argument context.Context to each HealthChecker.
+func (reg *Registry) HealthCheckCtx(ctx context.Context) map[string]errs.Err {
+	m := make(map[string]*daxSrcEntry)
+	for ent := reg.daxSrcEntryList.last; ent != nil; ent = ent.prev {
+		m[ent.name] = ent
+	}
+	return checkHealth(ctx, m)
+}
+
+// HealthCheck is the method that checks the health of DaxSrc(s) usable in this
+// DaxBase, which are local DaxSrc(s) and global DaxSrc(s) not hidden by local
+// ones of the same names.
+func (base *daxBaseImpl) HealthCheck() map[string]errs.Err {
+	return base.HealthCheckCtx(context.Background())
+}
+
+// HealthCheckCtx is the method that does the same as HealthCheck method, but
+// passes the argument context.Context to each HealthChecker.
+func (base *daxBaseImpl) HealthCheckCtx(ctx context.Context) map[string]errs.Err {
+	m := make(map[string]*daxSrcEntry)
+
+	for name, ent := range base.daxSrcEntryMap {
+		if ent.deleted && ent.local {
+			ent = nil
+			for gEnt := base.registry.daxSrcEntryList.head; gEnt != nil; gEnt = gEnt.next {
+				if gEnt.name == name {
+					ent = gEnt
+					break
+				}
+			}
+			if ent == nil {
+				continue
+			}
+		}
+		m[name] = ent
+	}
+
+	return checkHealth(ctx, m)
+}
+
+func checkHealth(ctx context.Context, m map[string]*daxSrcEntry) map[string]errs.Err {
+	var ag asyncGroupAsync[string]
+	ag.ctx = ctx
+
+	results := make(map[string]errs.Err)
+
+	for name, ent := range m {
+		hc, ok := ent.daxSrc().(HealthChecker)
+		if !ok {
+			continue
+		}
+		results[name] = errs.Ok()
+		ag.name = name
+		ag.Add(func() errs.Err {
+			return hc.HealthCheck(ctx)
+		})
+	}
+
+	ag.wait()
+
+	for ent := ag.errHead; ent != nil; ent = ent.next {
+		results[ent.name] = ent.err
+	}
+
+	return results
+}
@@ -0,0 +1,104 @@
+package sabi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type DaxSrcIsDown struct{}
+
+type HcDaxSrc struct {
+	FooDaxSrc
+	healthy bool
+	wait    time.Duration
+	gauge   *setupGauge
+}
+
+func (ds HcDaxSrc) HealthCheck(ctx context.Context) errs.Err {
+	if ds.gauge != nil {
+		ds.gauge.enter()
+		defer ds.gauge.leave()
+	}
+	select {
+	case <-time.After(ds.wait):
+	case <-ctx.Done():
+		return errs.New(DaxSrcIsDown{}, ctx.Err())
+	}
+	if !ds.healthy {
+		return errs.New(DaxSrcIsDown{})
+	}
+	return errs.Ok()
+}
+
+func TestHealthCheck_global(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	gauge := &setupGauge{}
+
+	Uses("foo", FooDaxSrc{})
+	Uses("hc1", HcDaxSrc{healthy: true, wait: 20 * time.Millisecond, gauge: gauge})
+	Uses("hc2", HcDaxSrc{healthy: false, wait: 20 * time.Millisecond, gauge: gauge})
+	Uses("hc1", HcDaxSrc{healthy: false})
+
+	err := Setup()
+	assert.True(t, err.IsOk())
+	defer Close()
+
+	m := HealthCheck()
+	assert.Equal(t, gauge.peakCount(), 2)
+
+	assert.Equal(t, len(m), 2)
+	assert.True(t, m["hc1"].IsOk())
+	assert.IsType(t, m["hc2"].Reason(), DaxSrcIsDown{})
+}
+
+func TestHealthCheckCtx_timeout(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("hc", HcDaxSrc{healthy: true, wait: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	m := HealthCheckCtx(ctx)
+	assert.Equal(t, len(m), 1)
+	assert.IsType(t, m["hc"].Reason(), DaxSrcIsDown{})
+	assert.Equal(t, m["hc"].Cause(), context.DeadlineExceeded)
+}
+
+func TestDax_HealthCheck(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("hc1", HcDaxSrc{healthy: true})
+	Uses("hc2", HcDaxSrc{healthy: true})
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("hc2", HcDaxSrc{healthy: false}).
+		IfOk(base.Uses_("hc3", HcDaxSrc{healthy: false})).
+		IfOk(base.Uses_("foo", FooDaxSrc{}))
+	assert.True(t, err.IsOk())
+
+	m := base.HealthCheck()
+	assert.Equal(t, len(m), 3)
+	assert.True(t, m["hc1"].IsOk())
+	assert.IsType(t, m["hc2"].Reason(), DaxSrcIsDown{})
+	assert.IsType(t, m["hc3"].Reason(), DaxSrcIsDown{})
+
+	base.Disuses("hc2")
+	base.Disuses("hc3")
+
+	m = base.HealthCheckCtx(context.Background())
+	assert.Equal(t, len(m), 2)
+	assert.True(t, m["hc1"].IsOk())
+	assert.True(t, m["hc2"].IsOk())
+}
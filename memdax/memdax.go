@@ -0,0 +1,236 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+// memdax is the package that provides a DaxSrc and a DaxConn for an in-memory
+// transactional key-value store.
+//
+// DaxConn buffers writes in a transaction, applies them to the store of
+// DaxSrc at Commit, discards them at Rollback, and undoes applied writes at
+// ForceBack.
+// Reads in a transaction see writes buffered in the same transaction and
+// values committed by other transactions.
+//
+//	sabi.Uses("greeting", memdax.NewDaxSrc[string, string]())
+//
+//	func (dax MapGreetDax) SetGreeting(name, greeting string) errs.Err {
+//	    conn, err := sabi.GetDaxConn[*memdax.DaxConn[string, string]](dax, "greeting")
+//	    if err.IsNotOk() {
+//	        return err
+//	    }
+//	    conn.Set(name, greeting)
+//	    return errs.Ok()
+//	}
+package memdax
+
+import (
+	"sync"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+)
+
+// DaxSrc is the struct type that implements sabi.DaxSrc for an in-memory
+// key-value store.
+type DaxSrc[K comparable, V any] struct {
+	mutex sync.RWMutex
+	data  map[K]V
+}
+
+// NewDaxSrc is the function that creates a new DaxSrc instance with an empty
+// store.
+func NewDaxSrc[K comparable, V any]() *DaxSrc[K, V] {
+	return &DaxSrc[K, V]{data: make(map[K]V)}
+}
+
+// WithData is the method to store the entries of the argument map in advance.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc[K, V]) WithData(data map[K]V) *DaxSrc[K, V] {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	for k, v := range data {
+		ds.data[k] = v
+	}
+	return ds
+}
+
+// Setup is the method which does nothing because the store is ready when
+// this DaxSrc is created.
+func (ds *DaxSrc[K, V]) Setup(ag sabi.AsyncGroup) errs.Err {
+	return errs.Ok()
+}
+
+// Close is the method which does nothing. The stored entries are kept so that
+// they can be inspected after transactions.
+func (ds *DaxSrc[K, V]) Close() {}
+
+// CreateDaxConn is the method to create a DaxConn instance.
+func (ds *DaxSrc[K, V]) CreateDaxConn() (sabi.DaxConn, errs.Err) {
+	return &DaxConn[K, V]{ds: ds, writes: make(map[K]write[V])}, errs.Ok()
+}
+
+// Get is the method to get a committed value of the argument key.
+// The second result is false if the key is not stored.
+func (ds *DaxSrc[K, V]) Get(key K) (V, bool) {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+	v, ok := ds.data[key]
+	return v, ok
+}
+
+// Snapshot is the method to get a copy of all committed entries.
+func (ds *DaxSrc[K, V]) Snapshot() map[K]V {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+	m := make(map[K]V, len(ds.data))
+	for k, v := range ds.data {
+		m[k] = v
+	}
+	return m
+}
+
+type write[V any] struct {
+	value   V
+	deleted bool
+}
+
+type undo[K comparable, V any] struct {
+	key    K
+	value  V
+	exists bool
+}
+
+// DaxConn is the struct type that implements sabi.DaxConn for an in-memory
+// key-value store.
+type DaxConn[K comparable, V any] struct {
+	ds        *DaxSrc[K, V]
+	mutex     sync.Mutex
+	writes    map[K]write[V]
+	order     []K
+	undos     []undo[K, V]
+	committed bool
+}
+
+// Get is the method to get a value of the argument key.
+// A value set or deleted in the current transaction takes precedence over a
+// committed one.
+// The second result is false if the key is not stored or deleted.
+func (conn *DaxConn[K, V]) Get(key K) (V, bool) {
+	conn.mutex.Lock()
+	w, ok := conn.writes[key]
+	conn.mutex.Unlock()
+
+	if ok {
+		if w.deleted {
+			var zero V
+			return zero, false
+		}
+		return w.value, true
+	}
+	return conn.ds.Get(key)
+}
+
+// Set is the method to buffer a write of the argument value for the argument
+// key, which is applied to the store at Commit.
+func (conn *DaxConn[K, V]) Set(key K, value V) {
+	conn.put(key, write[V]{value: value})
+}
+
+// Delete is the method to buffer a deletion of the argument key, which is
+// applied to the store at Commit.
+func (conn *DaxConn[K, V]) Delete(key K) {
+	conn.put(key, write[V]{deleted: true})
+}
+
+func (conn *DaxConn[K, V]) put(key K, w write[V]) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	if _, exists := conn.writes[key]; !exists {
+		conn.order = append(conn.order, key)
+	}
+	conn.writes[key] = w
+}
+
+// Commit is the method to apply buffered writes to the store atomically.
+func (conn *DaxConn[K, V]) Commit(ag sabi.AsyncGroup) errs.Err {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.ds.mutex.Lock()
+	defer conn.ds.mutex.Unlock()
+
+	undos := make([]undo[K, V], 0, len(conn.order))
+	for _, key := range conn.order {
+		v, exists := conn.ds.data[key]
+		undos = append(undos, undo[K, V]{key: key, value: v, exists: exists})
+
+		w := conn.writes[key]
+		if w.deleted {
+			delete(conn.ds.data, key)
+		} else {
+			conn.ds.data[key] = w.value
+		}
+	}
+
+	conn.undos = undos
+	conn.clearWrites()
+	conn.committed = true
+	return errs.Ok()
+}
+
+// IsCommitted is the method to check whether buffered writes are already
+// applied to the store.
+func (conn *DaxConn[K, V]) IsCommitted() bool {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	return conn.committed
+}
+
+// Rollback is the method to discard buffered writes.
+func (conn *DaxConn[K, V]) Rollback(ag sabi.AsyncGroup) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	conn.clearWrites()
+}
+
+// ForceBack is the method to restore values which were overwritten or deleted
+// by the commit of this DaxConn.
+// If this DaxConn is not committed, this method discards buffered writes.
+func (conn *DaxConn[K, V]) ForceBack(ag sabi.AsyncGroup) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	if !conn.committed {
+		conn.clearWrites()
+		return
+	}
+
+	conn.ds.mutex.Lock()
+	defer conn.ds.mutex.Unlock()
+
+	for i := len(conn.undos) - 1; i >= 0; i-- {
+		u := conn.undos[i]
+		if u.exists {
+			conn.ds.data[u.key] = u.value
+		} else {
+			delete(conn.ds.data, u.key)
+		}
+	}
+
+	conn.undos = nil
+	conn.committed = false
+}
+
+// Close is the method to discard buffered writes and undo information.
+func (conn *DaxConn[K, V]) Close() {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	conn.clearWrites()
+	conn.undos = nil
+}
+
+func (conn *DaxConn[K, V]) clearWrites() {
+	conn.writes = make(map[K]write[V])
+	conn.order = nil
+}
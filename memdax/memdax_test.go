@@ -0,0 +1,137 @@
+package memdax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+	"github.com/sttk/sabi/sabitest"
+)
+
+type (
+	FailToCommit struct{}
+	FailToDo     struct{}
+)
+
+func TestDaxConn_getSetDelete(t *testing.T) {
+	ds := NewDaxSrc[string, int]().WithData(map[string]int{"a": 1, "b": 2})
+
+	c, err := ds.CreateDaxConn()
+	assert.True(t, err.IsOk())
+	conn := c.(*DaxConn[string, int])
+
+	v, ok := conn.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, v, 1)
+
+	conn.Set("a", 10)
+	conn.Set("c", 3)
+	conn.Delete("b")
+
+	v, ok = conn.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, v, 10)
+	v, ok = conn.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, v, 3)
+	_, ok = conn.Get("b")
+	assert.False(t, ok)
+
+	assert.Equal(t, ds.Snapshot(), map[string]int{"a": 1, "b": 2})
+
+	assert.True(t, conn.Commit(nil).IsOk())
+	assert.True(t, conn.IsCommitted())
+	assert.Equal(t, ds.Snapshot(), map[string]int{"a": 10, "c": 3})
+
+	conn.ForceBack(nil)
+	assert.False(t, conn.IsCommitted())
+	assert.Equal(t, ds.Snapshot(), map[string]int{"a": 1, "b": 2})
+
+	conn.Close()
+}
+
+func TestDaxConn_Rollback(t *testing.T) {
+	ds := NewDaxSrc[string, int]()
+
+	c, _ := ds.CreateDaxConn()
+	conn := c.(*DaxConn[string, int])
+
+	conn.Set("a", 1)
+	conn.Rollback(nil)
+
+	_, ok := conn.Get("a")
+	assert.False(t, ok)
+
+	assert.True(t, conn.Commit(nil).IsOk())
+	assert.Equal(t, len(ds.Snapshot()), 0)
+}
+
+func TestDaxSrc_Txn(t *testing.T) {
+	ds := NewDaxSrc[string, string]()
+
+	reg := sabi.NewRegistry()
+	reg.Uses("greeting", ds)
+	assert.True(t, reg.Setup().IsOk())
+	defer reg.Close()
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err := sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn[string, string]](dax, "greeting")
+		if err.IsNotOk() {
+			return err
+		}
+		conn.Set("foo", "Hello, foo")
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+
+	v, ok := ds.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, v, "Hello, foo")
+
+	err = sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn[string, string]](dax, "greeting")
+		if err.IsNotOk() {
+			return err
+		}
+		conn.Set("foo", "Bye, foo")
+		conn.Set("bar", "Hello, bar")
+		return errs.New(FailToDo{})
+	})
+	assert.IsType(t, err.Reason(), FailToDo{})
+
+	assert.Equal(t, ds.Snapshot(), map[string]string{"foo": "Hello, foo"})
+}
+
+func TestDaxSrc_Txn_forceBack(t *testing.T) {
+	ds := NewDaxSrc[string, string]().WithData(map[string]string{"foo": "Hi"})
+
+	reg := sabi.NewRegistry()
+	reg.Uses("greeting", ds)
+	reg.Uses("output", sabitest.NewDaxSrc("output", nil).
+		WithCommitErr(errs.New(FailToCommit{})))
+	assert.True(t, reg.Setup().IsOk())
+	defer reg.Close()
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err := sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn[string, string]](dax, "greeting")
+		if err.IsNotOk() {
+			return err
+		}
+		conn.Set("foo", "Hello, foo")
+		conn.Set("bar", "Hello, bar")
+
+		_, err = sabi.GetDaxConn[*sabitest.DaxConn](dax, "output")
+		return err
+	})
+	assert.IsType(t, err.Reason(), sabi.FailToCommitDaxConn{})
+
+	assert.Equal(t, ds.Snapshot(), map[string]string{"foo": "Hi"})
+}
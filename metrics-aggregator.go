@@ -0,0 +1,281 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets is the upper bounds of histogram buckets which
+// MetricsAggregator uses by default, in seconds.
+var DefaultBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+var metricHelps = map[string]string{
+	MetricTxnTotal:              "Number of transactions by outcome.",
+	MetricCommitDuration:        "Seconds taken to commit a DaxConn.",
+	MetricCreateDaxConnFailures: "Number of failures to create a DaxConn.",
+	MetricDaxSrcSetupDuration:   "Seconds taken to set up a global DaxSrc.",
+	MetricSetupDuration:         "Seconds taken to set up all global DaxSrcs.",
+}
+
+// MetricsAggregator is the struct type that implements MetricsSink, and
+// aggregates metrics in the process.
+// The aggregated metrics can be rendered in the Prometheus text exposition
+// format with WritePrometheus method.
+// A MetricsAggregator is safe for concurrent use.
+//
+//	agg := sabi.NewMetricsAggregator()
+//	sabi.SetMetricsSink(agg)
+//
+//	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+//	    w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+//	    agg.WritePrometheus(w)
+//	})
+type MetricsAggregator struct {
+	mutex      sync.Mutex
+	buckets    []float64
+	counters   map[string]map[string]uint64
+	histograms map[string]map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewMetricsAggregator is the function that creates a new MetricsAggregator
+// instance.
+// The arguments are the upper bounds of histogram buckets, and DefaultBuckets
+// is used if no argument is specified.
+func NewMetricsAggregator(buckets ...float64) *MetricsAggregator {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	bs := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		if !math.IsInf(b, +1) {
+			bs = append(bs, b)
+		}
+	}
+	sort.Float64s(bs)
+
+	return &MetricsAggregator{
+		buckets:    bs,
+		counters:   make(map[string]map[string]uint64),
+		histograms: make(map[string]map[string]*histogram),
+	}
+}
+
+// IncCounter is the method to increment a counter which has the argument name
+// and labels by one.
+func (agg *MetricsAggregator) IncCounter(name string, labels map[string]string) {
+	key := formatLabels(labels)
+
+	agg.mutex.Lock()
+	defer agg.mutex.Unlock()
+
+	m, exists := agg.counters[name]
+	if !exists {
+		m = make(map[string]uint64)
+		agg.counters[name] = m
+	}
+	m[key]++
+}
+
+// ObserveHistogram is the method to add the argument value to a histogram
+// which has the argument name and labels.
+func (agg *MetricsAggregator) ObserveHistogram(
+	name string, labels map[string]string, value float64,
+) {
+	key := formatLabels(labels)
+
+	agg.mutex.Lock()
+	defer agg.mutex.Unlock()
+
+	m, exists := agg.histograms[name]
+	if !exists {
+		m = make(map[string]*histogram)
+		agg.histograms[name] = m
+	}
+	h, exists := m[key]
+	if !exists {
+		h = &histogram{counts: make([]uint64, len(agg.buckets))}
+		m[key] = h
+	}
+
+	for i, b := range agg.buckets {
+		if value <= b {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// Counter is the method to get the value of a counter which has the argument
+// name and labels.
+func (agg *MetricsAggregator) Counter(name string, labels map[string]string) uint64 {
+	key := formatLabels(labels)
+
+	agg.mutex.Lock()
+	defer agg.mutex.Unlock()
+
+	return agg.counters[name][key]
+}
+
+// HistogramCount is the method to get the number of values observed by a
+// histogram which has the argument name and labels.
+func (agg *MetricsAggregator) HistogramCount(name string, labels map[string]string) uint64 {
+	key := formatLabels(labels)
+
+	agg.mutex.Lock()
+	defer agg.mutex.Unlock()
+
+	h, exists := agg.histograms[name][key]
+	if !exists {
+		return 0
+	}
+	return h.count
+}
+
+// HistogramSum is the method to get the sum of values observed by a
+// histogram which has the argument name and labels.
+func (agg *MetricsAggregator) HistogramSum(name string, labels map[string]string) float64 {
+	key := formatLabels(labels)
+
+	agg.mutex.Lock()
+	defer agg.mutex.Unlock()
+
+	h, exists := agg.histograms[name][key]
+	if !exists {
+		return 0
+	}
+	return h.sum
+}
+
+// Reset is the method to clear all aggregated metrics.
+func (agg *MetricsAggregator) Reset() {
+	agg.mutex.Lock()
+	defer agg.mutex.Unlock()
+
+	agg.counters = make(map[string]map[string]uint64)
+	agg.histograms = make(map[string]map[string]*histogram)
+}
+
+// WritePrometheus is the method to write a snapshot of the aggregated metrics
+// in the Prometheus text exposition format to the argument io.Writer.
+// Metrics are written in order of their names, and series of a metric are
+// written in order of their labels.
+func (agg *MetricsAggregator) WritePrometheus(w io.Writer) error {
+	agg.mutex.Lock()
+	defer agg.mutex.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	names := make([]string, 0, len(agg.counters)+len(agg.histograms))
+	for name := range agg.counters {
+		names = append(names, name)
+	}
+	for name := range agg.histograms {
+		if _, exists := agg.counters[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if m, exists := agg.counters[name]; exists {
+			writeHeader(bw, name, "counter")
+			for _, key := range sortedKeys(m) {
+				bw.WriteString(name + key + " " + formatUint(m[key]) + "\n")
+			}
+		}
+		if m, exists := agg.histograms[name]; exists {
+			writeHeader(bw, name, "histogram")
+			for _, key := range sortedKeys(m) {
+				agg.writeHistogram(bw, name, key, m[key])
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+func (agg *MetricsAggregator) writeHistogram(
+	bw *bufio.Writer, name, key string, h *histogram,
+) {
+	var cumulative uint64
+	for i, b := range agg.buckets {
+		cumulative += h.counts[i]
+		le := strconv.FormatFloat(b, 'g', -1, 64)
+		bw.WriteString(name + "_bucket" + appendLabel(key, "le", le) + " " +
+			formatUint(cumulative) + "\n")
+	}
+	bw.WriteString(name + "_bucket" + appendLabel(key, "le", "+Inf") + " " +
+		formatUint(h.count) + "\n")
+	bw.WriteString(name + "_sum" + key + " " +
+		strconv.FormatFloat(h.sum, 'g', -1, 64) + "\n")
+	bw.WriteString(name + "_count" + key + " " + formatUint(h.count) + "\n")
+}
+
+func writeHeader(bw *bufio.Writer, name, typ string) {
+	if help, exists := metricHelps[name]; exists {
+		bw.WriteString("# HELP " + name + " " + help + "\n")
+	}
+	bw.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatUint(n uint64) string {
+	return strconv.FormatUint(n, 10)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats the argument labels into the form: {k1="v1",k2="v2"}
+// in order of their names, or an empty string if there is no label.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := sortedKeys(labels)
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name + `="` + labelValueReplacer.Replace(labels[name]) + `"`)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// appendLabel appends a label to the argument formatted labels.
+func appendLabel(key, name, value string) string {
+	label := name + `="` + labelValueReplacer.Replace(value) + `"`
+	if key == "" {
+		return "{" + label + "}"
+	}
+	return key[:len(key)-1] + "," + label + "}"
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"github.com/sttk/sabi/errs"
+)
+
+// Names of metrics which sabi records.
+const (
+	// MetricTxnTotal is the name of a counter of transactions, labeled with
+	// LabelOutcome.
+	// Nested transactions are not counted, because their updates are settled
+	// by the outermost transactions.
+	MetricTxnTotal = "sabi_txn_total"
+
+	// MetricCommitDuration is the name of a histogram of seconds taken to
+	// commit each DaxConn, labeled with LabelDaxConn.
+	MetricCommitDuration = "sabi_daxconn_commit_duration_seconds"
+
+	// MetricCreateDaxConnFailures is the name of a counter of failures to
+	// create DaxConn(s), labeled with LabelDaxSrc.
+	MetricCreateDaxConnFailures = "sabi_create_daxconn_failures_total"
+
+	// MetricDaxSrcSetupDuration is the name of a histogram of seconds taken to
+	// set up each global DaxSrc, labeled with LabelDaxSrc.
+	MetricDaxSrcSetupDuration = "sabi_daxsrc_setup_duration_seconds"
+
+	// MetricSetupDuration is the name of a histogram of seconds taken to set up
+	// all global DaxSrc(s).
+	MetricSetupDuration = "sabi_setup_duration_seconds"
+)
+
+// Names of labels of metrics which sabi records.
+const (
+	// LabelOutcome is the label name of MetricTxnTotal, of which the value is
+	// one of OutcomeCommitted, OutcomeRolledBack, OutcomeForceBacked, and
+	// OutcomeReadOnly.
+	LabelOutcome = "outcome"
+
+	// LabelDaxConn is the label name of which the value is the name of a
+	// DaxConn.
+	LabelDaxConn = "daxconn"
+
+	// LabelDaxSrc is the label name of which the value is the registered name
+	// of a DaxSrc.
+	LabelDaxSrc = "daxsrc"
+)
+
+// Values of LabelOutcome.
+const (
+	// OutcomeCommitted is the outcome of a transaction of which all DaxConn(s)
+	// were committed.
+	OutcomeCommitted = "committed"
+
+	// OutcomeRolledBack is the outcome of a transaction which failed and of
+	// which no DaxConn was committed before the rollback.
+	OutcomeRolledBack = "rolled_back"
+
+	// OutcomeForceBacked is the outcome of a transaction which failed after
+	// some DaxConn(s) were committed, so that those were forced back.
+	OutcomeForceBacked = "force_backed"
+
+	// OutcomeReadOnly is the outcome of a read-only transaction which
+	// succeeded.
+	OutcomeReadOnly = "read_only"
+)
+
+// MetricsSink is the interface to receive metrics which sabi records.
+// IncCounter is the method to increment a counter which has the argument name
+// and labels by one.
+// ObserveHistogram is the method to add the argument value to a histogram
+// which has the argument name and labels.
+// Durations are observed in seconds.
+// The argument labels can be nil, and must not be modified.
+//
+// Implementations of this interface can adapt sabi to metrics libraries, or
+// MetricsAggregator can be used as a built-in implementation.
+type MetricsSink interface {
+	IncCounter(name string, labels map[string]string)
+	ObserveHistogram(name string, labels map[string]string, value float64)
+}
+
+type noopMetricsSink struct{}
+
+func (s noopMetricsSink) IncCounter(name string, labels map[string]string) {}
+func (s noopMetricsSink) ObserveHistogram(name string, labels map[string]string, value float64) {
+}
+
+// SetMetricsSink is the function to set a MetricsSink which metrics of
+// transactions of all DaxBase(s) and of Setup are recorded to.
+// If the argument MetricsSink is nil, the MetricsSink which does nothing is
+// set.
+//
+// Like Uses function, this function ignores setting a new MetricsSink after
+// Setup or beginning of Txn.
+func SetMetricsSink(sink MetricsSink) {
+	defaultRegistry.SetMetricsSink(sink)
+}
+
+// SetMetricsSink is the method to set a MetricsSink which metrics of
+// transactions of all DaxBase(s) created by this Registry and of Setup of
+// this Registry are recorded to.
+// See SetMetricsSink function for details.
+func (reg *Registry) SetMetricsSink(sink MetricsSink) {
+	if reg.isDaxSrcsFixed {
+		return
+	}
+
+	if sink == nil {
+		sink = noopMetricsSink{}
+	}
+	reg.metrics = sink
+}
+
+func (base *daxBaseImpl) getMetricsSink() MetricsSink {
+	return base.registry.metrics
+}
+
+func recordTxnOutcome(base DaxBase, readOnly bool, err errs.Err, forceBacked bool) {
+	var outcome string
+	switch {
+	case forceBacked:
+		outcome = OutcomeForceBacked
+	case err.IsNotOk():
+		outcome = OutcomeRolledBack
+	case readOnly:
+		outcome = OutcomeReadOnly
+	default:
+		outcome = OutcomeCommitted
+	}
+	base.getMetricsSink().IncCounter(MetricTxnTotal,
+		map[string]string{LabelOutcome: outcome})
+}
@@ -0,0 +1,194 @@
+package sabi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+func TestMetricsAggregator_WritePrometheus(t *testing.T) {
+	agg := NewMetricsAggregator(0.1, 1)
+
+	agg.IncCounter("foo_total", map[string]string{"b": "2", "a": "1"})
+	agg.IncCounter("foo_total", map[string]string{"a": "1", "b": "2"})
+	agg.IncCounter("foo_total", map[string]string{"a": "x\"y\\z\n"})
+	agg.IncCounter(MetricTxnTotal, nil)
+	agg.ObserveHistogram("bar_seconds", map[string]string{"a": "1"}, 0.05)
+	agg.ObserveHistogram("bar_seconds", map[string]string{"a": "1"}, 0.5)
+	agg.ObserveHistogram("bar_seconds", map[string]string{"a": "1"}, 2)
+	agg.ObserveHistogram(MetricSetupDuration, nil, 0.25)
+
+	assert.Equal(t, agg.Counter("foo_total", map[string]string{"a": "1", "b": "2"}), uint64(2))
+	assert.Equal(t, agg.Counter("foo_total", nil), uint64(0))
+	assert.Equal(t, agg.HistogramCount("bar_seconds", map[string]string{"a": "1"}), uint64(3))
+	assert.Equal(t, agg.HistogramSum("bar_seconds", map[string]string{"a": "1"}), 2.55)
+	assert.Equal(t, agg.HistogramCount("bar_seconds", nil), uint64(0))
+
+	var sb strings.Builder
+	assert.Nil(t, agg.WritePrometheus(&sb))
+	assert.Equal(t, sb.String(), ""+
+		"# TYPE bar_seconds histogram\n"+
+		"bar_seconds_bucket{a=\"1\",le=\"0.1\"} 1\n"+
+		"bar_seconds_bucket{a=\"1\",le=\"1\"} 2\n"+
+		"bar_seconds_bucket{a=\"1\",le=\"+Inf\"} 3\n"+
+		"bar_seconds_sum{a=\"1\"} 2.55\n"+
+		"bar_seconds_count{a=\"1\"} 3\n"+
+		"# TYPE foo_total counter\n"+
+		"foo_total{a=\"1\",b=\"2\"} 2\n"+
+		"foo_total{a=\"x\\\"y\\\\z\\n\"} 1\n"+
+		"# HELP sabi_setup_duration_seconds Seconds taken to set up all global DaxSrcs.\n"+
+		"# TYPE sabi_setup_duration_seconds histogram\n"+
+		"sabi_setup_duration_seconds_bucket{le=\"0.1\"} 0\n"+
+		"sabi_setup_duration_seconds_bucket{le=\"1\"} 1\n"+
+		"sabi_setup_duration_seconds_bucket{le=\"+Inf\"} 1\n"+
+		"sabi_setup_duration_seconds_sum 0.25\n"+
+		"sabi_setup_duration_seconds_count 1\n"+
+		"# HELP sabi_txn_total Number of transactions by outcome.\n"+
+		"# TYPE sabi_txn_total counter\n"+
+		"sabi_txn_total 1\n")
+
+	agg.Reset()
+	sb.Reset()
+	assert.Nil(t, agg.WritePrometheus(&sb))
+	assert.Equal(t, sb.String(), "")
+}
+
+func TestNewMetricsAggregator_defaultBuckets(t *testing.T) {
+	agg := NewMetricsAggregator()
+	assert.Equal(t, agg.buckets, DefaultBuckets)
+}
+
+func TestSetMetricsSink(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	assert.Equal(t, defaultRegistry.metrics, noopMetricsSink{})
+
+	agg := NewMetricsAggregator()
+	SetMetricsSink(agg)
+	assert.Equal(t, defaultRegistry.metrics, agg)
+
+	SetMetricsSink(nil)
+	assert.Equal(t, defaultRegistry.metrics, noopMetricsSink{})
+
+	SetMetricsSink(agg)
+	assert.True(t, Setup().IsOk())
+	defer Close()
+
+	SetMetricsSink(nil)
+	assert.Equal(t, defaultRegistry.metrics, agg)
+
+	Reset()
+	assert.Equal(t, defaultRegistry.metrics, noopMetricsSink{})
+}
+
+type FailToRunLogic struct{}
+
+func TestMetrics_Txn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	agg := NewMetricsAggregator()
+
+	reg := NewRegistry()
+	reg.SetMetricsSink(agg)
+	reg.Uses("foo", FooDaxSrc{})
+	reg.Uses("bar", &BarDaxSrc{})
+	assert.True(t, reg.Setup().IsOk())
+	defer reg.Close()
+
+	assert.Equal(t, agg.HistogramCount(MetricSetupDuration, nil), uint64(1))
+	assert.Equal(t, agg.HistogramCount(MetricDaxSrcSetupDuration,
+		map[string]string{LabelDaxSrc: "foo"}), uint64(1))
+	assert.Equal(t, agg.HistogramCount(MetricDaxSrcSetupDuration,
+		map[string]string{LabelDaxSrc: "bar"}), uint64(1))
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	useFooAndBar := func(dax Dax) errs.Err {
+		_, err := dax.getDaxConn("foo")
+		if err.IsNotOk() {
+			return err
+		}
+		_, err = dax.getDaxConn("bar")
+		return err
+	}
+
+	err := Txn(base, useFooAndBar)
+	assert.True(t, err.IsOk())
+
+	WillFailToCommitBarDaxConn = true
+	err = Txn(base, useFooAndBar)
+	assert.IsType(t, err.Reason(), FailToCommitDaxConn{})
+	WillFailToCommitBarDaxConn = false
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		return errs.New(FailToRunLogic{})
+	})
+	assert.IsType(t, err.Reason(), FailToRunLogic{})
+
+	WillFailToCreateFooDaxConn = true
+	err = Txn(base, useFooAndBar)
+	assert.IsType(t, err.Reason(), FailToCreateDaxConn{})
+	WillFailToCreateFooDaxConn = false
+
+	err = TxnReadOnly(base, useFooAndBar)
+	assert.True(t, err.IsOk())
+
+	outcome := func(o string) map[string]string {
+		return map[string]string{LabelOutcome: o}
+	}
+	assert.Equal(t, agg.Counter(MetricTxnTotal, outcome(OutcomeCommitted)), uint64(1))
+	assert.Equal(t, agg.Counter(MetricTxnTotal, outcome(OutcomeForceBacked)), uint64(1))
+	assert.Equal(t, agg.Counter(MetricTxnTotal, outcome(OutcomeRolledBack)), uint64(2))
+	assert.Equal(t, agg.Counter(MetricTxnTotal, outcome(OutcomeReadOnly)), uint64(1))
+
+	assert.Equal(t, agg.HistogramCount(MetricCommitDuration,
+		map[string]string{LabelDaxConn: "foo"}), uint64(2))
+	assert.Equal(t, agg.HistogramCount(MetricCommitDuration,
+		map[string]string{LabelDaxConn: "bar"}), uint64(2))
+
+	assert.Equal(t, agg.Counter(MetricCreateDaxConnFailures,
+		map[string]string{LabelDaxSrc: "foo"}), uint64(1))
+	assert.Equal(t, agg.Counter(MetricCreateDaxConnFailures,
+		map[string]string{LabelDaxSrc: "bar"}), uint64(0))
+}
+
+func TestMetrics_nestedTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetSavepoint()
+	defer resetSavepoint()
+
+	agg := NewMetricsAggregator()
+	SetMetricsSink(agg)
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("sp", SpDaxSrc{})
+	assert.True(t, err.IsOk())
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := GetDaxConn[*SpDaxConn](dax, "sp")
+		assert.True(t, err.IsOk())
+
+		err = Txn(base, func(dax Dax) errs.Err {
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+
+		return errs.New(FailToRunLogic{})
+	})
+	assert.IsType(t, err.Reason(), FailToRunLogic{})
+
+	outcome := func(o string) map[string]string {
+		return map[string]string{LabelOutcome: o}
+	}
+	assert.Equal(t, agg.Counter(MetricTxnTotal, outcome(OutcomeCommitted)), uint64(0))
+	assert.Equal(t, agg.Counter(MetricTxnTotal, outcome(OutcomeRolledBack)), uint64(1))
+}
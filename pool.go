@@ -0,0 +1,367 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type /* error reasons */ (
+	// PoolIsClosed is the error reason which indicates that a PooledDaxSrc is
+	// already closed when a connection is checked out.
+	PoolIsClosed struct{}
+
+	// PooledConnIsNotAvailable is the error reason which indicates that a
+	// context.Context is canceled or its deadline is exceeded while waiting for
+	// a connection to be returned to a PooledDaxSrc.
+	// An Err of this reason has the error of context.Context as its cause.
+	PooledConnIsNotAvailable struct{}
+)
+
+// PoolConfig is the struct type that configures a PooledDaxSrc.
+//
+// MaxOpen is the maximum number of open connections, which are in use or idle.
+// MaxIdle is the maximum number of idle connections kept in the pool.
+// If MaxOpen or MaxIdle is zero or less, the number is not limited.
+// IdleTimeout is the maximum duration that a connection is kept idle.
+// Idle connections are checked for IdleTimeout and MaxLifetime only when a
+// connection is checked out, so they are kept open while a PooledDaxSrc is not
+// used.
+// MaxLifetime is the maximum duration that a connection is used from its
+// opening.
+// If IdleTimeout or MaxLifetime is zero or less, the duration is not limited.
+type PoolConfig struct {
+	MaxOpen     int
+	MaxIdle     int
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+}
+
+// PoolStats is the struct type that has statistics of a PooledDaxSrc.
+//
+// Open is the number of open connections, and InUse and Idle are the numbers
+// of connections in use and idle.
+// WaitCount is the total number of times to wait for a connection, and
+// WaitDuration is the total time waited.
+// MaxIdleClosed, IdleTimeoutClosed, MaxLifetimeClosed and InvalidClosed are
+// the total numbers of connections closed due to PoolConfig#MaxIdle,
+// PoolConfig#IdleTimeout, PoolConfig#MaxLifetime and failures of validation.
+type PoolStats struct {
+	MaxOpen           int
+	Open              int
+	InUse             int
+	Idle              int
+	WaitCount         int64
+	WaitDuration      time.Duration
+	MaxIdleClosed     int64
+	IdleTimeoutClosed int64
+	MaxLifetimeClosed int64
+	InvalidClosed     int64
+}
+
+// PoolFactory is the interface that a PooledDaxSrc uses to manage pooled
+// resources, such as clients connected to a data store.
+//
+// Open is the method to open a new resource.
+// Validate is the method to check whether an idle resource is still usable
+// when it is checked out from the pool.
+// Destroy is the method to close a resource which is removed from the pool.
+// NewDaxConn is the method to create a DaxConn which uses a checked out
+// resource.
+// The resource is returned to the pool after Close method of the created
+// DaxConn is called, so the DaxConn need not call PooledConn#Release.
+// The DaxConn can call PooledConn#Discard if the resource becomes unusable.
+type PoolFactory[R any] interface {
+	Open() (R, errs.Err)
+	Validate(res R) bool
+	Destroy(res R)
+	NewDaxConn(pc *PooledConn[R]) (DaxConn, errs.Err)
+}
+
+// PooledConn is the struct type that holds a resource checked out from a
+// PooledDaxSrc.
+type PooledConn[R any] struct {
+	res       R
+	ds        *PooledDaxSrc[R]
+	createdAt time.Time
+	usedAt    time.Time
+	released  bool
+}
+
+// Resource is the method to get the resource held by this PooledConn.
+func (pc *PooledConn[R]) Resource() R {
+	return pc.res
+}
+
+// Release is the method to return the resource to the pool.
+// If the pool is closed or has enough idle resources, or the resource exceeds
+// PoolConfig#MaxLifetime, the resource is destroyed instead.
+func (pc *PooledConn[R]) Release() {
+	pc.ds.release(pc, false)
+}
+
+// Discard is the method to destroy the resource without returning it to the
+// pool.
+func (pc *PooledConn[R]) Discard() {
+	pc.ds.release(pc, true)
+}
+
+// PooledDaxSrc is the struct type of DaxSrc which pools resources created by a
+// PoolFactory, and creates DaxConn(s) using them.
+type PooledDaxSrc[R any] struct {
+	factory PoolFactory[R]
+	cfg     PoolConfig
+	slots   chan struct{}
+
+	mutex   sync.Mutex
+	idle    []*PooledConn[R]
+	stats   PoolStats
+	closed  bool
+	closeCh chan struct{}
+}
+
+// NewPooledDaxSrc is the function that creates a new PooledDaxSrc instance
+// with a PoolFactory and a PoolConfig.
+func NewPooledDaxSrc[R any](factory PoolFactory[R], cfg PoolConfig) *PooledDaxSrc[R] {
+	ds := &PooledDaxSrc[R]{factory: factory, cfg: cfg, closeCh: make(chan struct{})}
+	if cfg.MaxOpen > 0 {
+		ds.slots = make(chan struct{}, cfg.MaxOpen)
+	}
+	ds.stats.MaxOpen = cfg.MaxOpen
+	return ds
+}
+
+// Setup is the method to make this PooledDaxSrc usable.
+func (ds *PooledDaxSrc[R]) Setup(ag AsyncGroup) errs.Err {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	if ds.closed {
+		ds.closed = false
+		ds.closeCh = make(chan struct{})
+	}
+	return errs.Ok()
+}
+
+// Close is the method to destroy all idle resources and to forbid checking
+// out resources.
+// Resources in use are destroyed when they are released.
+func (ds *PooledDaxSrc[R]) Close() {
+	ds.mutex.Lock()
+	if !ds.closed {
+		ds.closed = true
+		close(ds.closeCh)
+	}
+	idle := ds.idle
+	ds.idle = nil
+	ds.stats.Idle = 0
+	ds.stats.Open -= len(idle)
+	ds.mutex.Unlock()
+
+	for _, pc := range idle {
+		ds.factory.Destroy(pc.res)
+	}
+}
+
+// CreateDaxConn is the method to check out a resource from the pool and to
+// create a DaxConn with it.
+// The created DaxConn wraps the DaxConn created by PoolFactory#NewDaxConn, and
+// returns the resource to the pool when it is closed.
+// GetDaxConn function casts the wrapped DaxConn.
+// If the number of open resources reaches PoolConfig#MaxOpen, this method
+// waits until a resource is returned.
+func (ds *PooledDaxSrc[R]) CreateDaxConn() (DaxConn, errs.Err) {
+	return ds.CreateDaxConnCtx(context.Background())
+}
+
+// CreateDaxConnCtx is the method that does the same as CreateDaxConn method,
+// but stops waiting for a resource when the argument context.Context is
+// canceled.
+func (ds *PooledDaxSrc[R]) CreateDaxConnCtx(ctx context.Context) (DaxConn, errs.Err) {
+	pc, err := ds.checkout(ctx)
+	if err.IsNotOk() {
+		return nil, err
+	}
+
+	conn, err := ds.factory.NewDaxConn(pc)
+	if err.IsNotOk() {
+		pc.Release()
+		return nil, err
+	}
+
+	return &pooledDaxConn[R]{DaxConn: conn, pc: pc}, errs.Ok()
+}
+
+// Stats is the method to get the statistics of this PooledDaxSrc.
+func (ds *PooledDaxSrc[R]) Stats() PoolStats {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	return ds.stats
+}
+
+func (ds *PooledDaxSrc[R]) checkout(ctx context.Context) (*PooledConn[R], errs.Err) {
+	ds.mutex.Lock()
+	closeCh := ds.closeCh
+	ds.mutex.Unlock()
+
+	if ds.slots != nil {
+		select {
+		case ds.slots <- struct{}{}:
+		default:
+			start := time.Now()
+			select {
+			case ds.slots <- struct{}{}:
+			case <-closeCh:
+				return nil, errs.New(PoolIsClosed{})
+			case <-ctx.Done():
+				return nil, errs.New(PooledConnIsNotAvailable{}, ctx.Err())
+			}
+			ds.mutex.Lock()
+			ds.stats.WaitCount++
+			ds.stats.WaitDuration += time.Since(start)
+			ds.mutex.Unlock()
+		}
+	}
+
+	now := time.Now()
+	var expired []*PooledConn[R]
+
+	ds.mutex.Lock()
+
+	if ds.closed {
+		ds.mutex.Unlock()
+		ds.releaseSlot()
+		return nil, errs.New(PoolIsClosed{})
+	}
+
+	var pc *PooledConn[R]
+	for len(ds.idle) > 0 {
+		last := len(ds.idle) - 1
+		c := ds.idle[last]
+		ds.idle = ds.idle[:last]
+		ds.stats.Idle--
+
+		if ds.cfg.IdleTimeout > 0 && now.Sub(c.usedAt) > ds.cfg.IdleTimeout {
+			ds.stats.IdleTimeoutClosed++
+			expired = append(expired, c)
+			continue
+		}
+		if ds.cfg.MaxLifetime > 0 && now.Sub(c.createdAt) > ds.cfg.MaxLifetime {
+			ds.stats.MaxLifetimeClosed++
+			expired = append(expired, c)
+			continue
+		}
+		pc = c
+		break
+	}
+	ds.stats.Open -= len(expired)
+
+	if pc != nil {
+		ds.stats.InUse++
+	}
+
+	ds.mutex.Unlock()
+
+	for _, c := range expired {
+		ds.factory.Destroy(c.res)
+	}
+
+	if pc != nil {
+		if ds.factory.Validate(pc.res) {
+			pc.usedAt = now
+			pc.released = false
+			return pc, errs.Ok()
+		}
+
+		ds.mutex.Lock()
+		ds.stats.InUse--
+		ds.stats.Open--
+		ds.stats.InvalidClosed++
+		ds.mutex.Unlock()
+
+		ds.factory.Destroy(pc.res)
+	}
+
+	res, err := ds.factory.Open()
+	if err.IsNotOk() {
+		ds.releaseSlot()
+		return nil, err
+	}
+
+	ds.mutex.Lock()
+	ds.stats.Open++
+	ds.stats.InUse++
+	ds.mutex.Unlock()
+
+	return &PooledConn[R]{res: res, ds: ds, createdAt: now, usedAt: now}, errs.Ok()
+}
+
+func (ds *PooledDaxSrc[R]) release(pc *PooledConn[R], discard bool) {
+	ds.mutex.Lock()
+
+	if pc.released {
+		ds.mutex.Unlock()
+		return
+	}
+	pc.released = true
+	ds.stats.InUse--
+
+	now := time.Now()
+	keep := !discard && !ds.closed
+
+	if keep && ds.cfg.MaxLifetime > 0 && now.Sub(pc.createdAt) > ds.cfg.MaxLifetime {
+		ds.stats.MaxLifetimeClosed++
+		keep = false
+	}
+	if keep && ds.cfg.MaxIdle > 0 && len(ds.idle) >= ds.cfg.MaxIdle {
+		ds.stats.MaxIdleClosed++
+		keep = false
+	}
+
+	if keep {
+		pc.usedAt = now
+		ds.idle = append(ds.idle, pc)
+		ds.stats.Idle++
+	} else {
+		ds.stats.Open--
+	}
+
+	ds.mutex.Unlock()
+
+	if !keep {
+		ds.factory.Destroy(pc.res)
+	}
+	ds.releaseSlot()
+}
+
+type pooledDaxConn[R any] struct {
+	DaxConn
+	pc *PooledConn[R]
+}
+
+func (conn *pooledDaxConn[R]) Close() {
+	defer conn.pc.Release()
+	conn.DaxConn.Close()
+}
+
+func (conn *pooledDaxConn[R]) CloseWithErr() errs.Err {
+	defer conn.pc.Release()
+	return closeWithErr(conn.DaxConn)
+}
+
+func (conn *pooledDaxConn[R]) unwrapDaxConn() DaxConn {
+	return conn.DaxConn
+}
+
+func (ds *PooledDaxSrc[R]) releaseSlot() {
+	if ds.slots != nil {
+		<-ds.slots
+	}
+}
@@ -0,0 +1,299 @@
+package sabi
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type PoolClient struct {
+	id    int
+	valid bool
+}
+
+type PoolClientFactory struct {
+	count int
+}
+
+type FailToOpenPoolClient struct{}
+
+var WillFailToOpenPoolClient bool
+
+func (f *PoolClientFactory) Open() (*PoolClient, errs.Err) {
+	if WillFailToOpenPoolClient {
+		return nil, errs.New(FailToOpenPoolClient{})
+	}
+	f.count++
+	Logs.PushBack("Open client" + strconv.Itoa(f.count))
+	return &PoolClient{id: f.count, valid: true}, errs.Ok()
+}
+func (f *PoolClientFactory) Validate(c *PoolClient) bool {
+	return c.valid
+}
+func (f *PoolClientFactory) Destroy(c *PoolClient) {
+	Logs.PushBack("Destroy client" + strconv.Itoa(c.id))
+}
+func (f *PoolClientFactory) NewDaxConn(pc *PooledConn[*PoolClient]) (DaxConn, errs.Err) {
+	return &PoolDaxConn{pc}, errs.Ok()
+}
+
+type PoolDaxConn struct {
+	*PooledConn[*PoolClient]
+}
+
+func (conn *PoolDaxConn) Commit(ag AsyncGroup) errs.Err {
+	return errs.Ok()
+}
+func (conn *PoolDaxConn) IsCommitted() bool {
+	return false
+}
+func (conn *PoolDaxConn) Rollback(ag AsyncGroup)  {}
+func (conn *PoolDaxConn) ForceBack(ag AsyncGroup) {}
+func (conn *PoolDaxConn) Close()                  {}
+
+func checkout(t *testing.T, ds *PooledDaxSrc[*PoolClient]) DaxConn {
+	conn, err := ds.CreateDaxConn()
+	assert.True(t, err.IsOk())
+	return conn
+}
+
+func clientOf(conn DaxConn) *PoolClient {
+	return unwrapDaxConn(conn).(*PoolDaxConn).Resource()
+}
+
+func TestPooledDaxSrc_reuse(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{})
+	assert.True(t, ds.Setup(&asyncGroupSync{}).IsOk())
+
+	conn1 := checkout(t, ds)
+	assert.Equal(t, clientOf(conn1).id, 1)
+	assert.Equal(t, ds.Stats().Open, 1)
+	assert.Equal(t, ds.Stats().InUse, 1)
+	assert.Equal(t, ds.Stats().Idle, 0)
+
+	conn1.Close()
+	conn1.Close()
+	assert.Equal(t, ds.Stats().Open, 1)
+	assert.Equal(t, ds.Stats().InUse, 0)
+	assert.Equal(t, ds.Stats().Idle, 1)
+
+	conn2 := checkout(t, ds)
+	assert.Equal(t, clientOf(conn2).id, 1)
+	conn3 := checkout(t, ds)
+	assert.Equal(t, clientOf(conn3).id, 2)
+	assert.Equal(t, ds.Stats().Open, 2)
+	assert.Equal(t, ds.Stats().InUse, 2)
+
+	conn2.Close()
+	unwrapDaxConn(conn3).(*PoolDaxConn).Discard()
+	assert.Equal(t, ds.Stats().Open, 1)
+	assert.Equal(t, ds.Stats().InUse, 0)
+	assert.Equal(t, ds.Stats().Idle, 1)
+
+	ds.Close()
+	assert.Equal(t, ds.Stats().Open, 0)
+	assert.Equal(t, ds.Stats().Idle, 0)
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open client1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Open client2")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Destroy client2")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Destroy client1")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestPooledDaxSrc_maxOpen(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{MaxOpen: 1})
+	defer ds.Close()
+
+	conn1 := checkout(t, ds)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := ds.CreateDaxConnCtx(ctx)
+	switch err.Reason().(type) {
+	case PooledConnIsNotAvailable:
+		assert.Equal(t, err.Cause(), context.DeadlineExceeded)
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	time.AfterFunc(10*time.Millisecond, conn1.Close)
+	conn2 := checkout(t, ds)
+	assert.Equal(t, clientOf(conn2).id, 1)
+
+	stats := ds.Stats()
+	assert.Equal(t, stats.MaxOpen, 1)
+	assert.Equal(t, stats.Open, 1)
+	assert.Equal(t, stats.WaitCount, int64(1))
+	assert.True(t, stats.WaitDuration > 0)
+	conn2.Close()
+}
+
+func TestPooledDaxSrc_maxIdle(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{MaxIdle: 1})
+	defer ds.Close()
+
+	conn1 := checkout(t, ds)
+	conn2 := checkout(t, ds)
+	conn1.Close()
+	conn2.Close()
+
+	stats := ds.Stats()
+	assert.Equal(t, stats.Open, 1)
+	assert.Equal(t, stats.Idle, 1)
+	assert.Equal(t, stats.MaxIdleClosed, int64(1))
+}
+
+func TestPooledDaxSrc_idleTimeoutAndMaxLifetime(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{
+		IdleTimeout: 10 * time.Millisecond,
+	})
+	defer ds.Close()
+
+	checkout(t, ds).Close()
+	time.Sleep(20 * time.Millisecond)
+	conn := checkout(t, ds)
+	assert.Equal(t, clientOf(conn).id, 2)
+	assert.Equal(t, ds.Stats().IdleTimeoutClosed, int64(1))
+	conn.Close()
+
+	ds = NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{
+		MaxLifetime: 10 * time.Millisecond,
+	})
+	defer ds.Close()
+
+	conn = checkout(t, ds)
+	time.Sleep(20 * time.Millisecond)
+	conn.Close()
+	assert.Equal(t, ds.Stats().MaxLifetimeClosed, int64(1))
+	assert.Equal(t, ds.Stats().Open, 0)
+}
+
+func TestPooledDaxSrc_validate(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{})
+	defer ds.Close()
+
+	conn := checkout(t, ds)
+	clientOf(conn).valid = false
+	conn.Close()
+
+	conn = checkout(t, ds)
+	assert.Equal(t, clientOf(conn).id, 2)
+	assert.Equal(t, ds.Stats().InvalidClosed, int64(1))
+	assert.Equal(t, ds.Stats().Open, 1)
+	conn.Close()
+}
+
+func TestPooledDaxSrc_failToOpen(t *testing.T) {
+	Reset()
+	defer Reset()
+	defer func() { WillFailToOpenPoolClient = false }()
+
+	ds := NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{MaxOpen: 1})
+	defer ds.Close()
+
+	WillFailToOpenPoolClient = true
+	_, err := ds.CreateDaxConn()
+	assert.IsType(t, err.Reason(), FailToOpenPoolClient{})
+
+	WillFailToOpenPoolClient = false
+	checkout(t, ds).Close()
+	assert.Equal(t, ds.Stats().Open, 1)
+}
+
+func TestPooledDaxSrc_closed(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{MaxOpen: 1})
+	conn := checkout(t, ds)
+	ds.Close()
+
+	_, err := ds.CreateDaxConnCtx(context.Background())
+	assert.IsType(t, err.Reason(), PoolIsClosed{})
+
+	conn.Close()
+	assert.Equal(t, ds.Stats().Open, 0)
+
+	_, err = ds.CreateDaxConn()
+	assert.IsType(t, err.Reason(), PoolIsClosed{})
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open client1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Destroy client1")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestPooledDaxSrc_withTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{MaxOpen: 2})
+	Uses("pool", ds)
+	assert.True(t, Setup().IsOk())
+	defer Close()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	for i := 0; i < 3; i++ {
+		err := Txn(base, func(dax Dax) errs.Err {
+			conn, err := GetDaxConn[*PoolDaxConn](dax, "pool")
+			assert.True(t, err.IsOk())
+			assert.Equal(t, conn.Resource().id, 1)
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+	}
+
+	assert.Equal(t, ds.Stats().Open, 1)
+	assert.Equal(t, ds.Stats().Idle, 1)
+}
+
+func TestPooledDaxSrc_releaseByFactoryDaxConn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewPooledDaxSrc[*PoolClient](&PoolClientFactory{}, PoolConfig{MaxOpen: 1})
+	defer ds.Close()
+
+	conn := checkout(t, ds)
+	unwrapDaxConn(conn).(*PoolDaxConn).Release()
+	conn.Close()
+
+	stats := ds.Stats()
+	assert.Equal(t, stats.Open, 1)
+	assert.Equal(t, stats.InUse, 0)
+	assert.Equal(t, stats.Idle, 1)
+
+	conn = checkout(t, ds)
+	assert.Equal(t, clientOf(conn).id, 1)
+	conn.Close()
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type /* error reasons */ (
+	// WriteIsAttemptedInReadOnlyTxn is the error reason which indicates that
+	// some DaxConn(s) reported that writes were attempted in a read-only
+	// transaction.
+	// The field Names is the registered names of those DaxConn(s).
+	WriteIsAttemptedInReadOnlyTxn struct {
+		Names []string
+	}
+
+	// ReadOnlyTxnIsNestedInReadWriteTxn is the error reason which indicates
+	// that a read-only transaction is begun in a logic function of a
+	// transaction which is not read-only on the same DaxBase.
+	ReadOnlyTxnIsNestedInReadWriteTxn struct{}
+)
+
+// ReadOnlyDaxConn is the optional interface which a DaxConn implements to
+// work in a read-only transaction.
+//
+// SetReadOnly is the method called when this DaxConn is created in a
+// read-only transaction.
+// IsWriteAttempted is the method to check whether a write was attempted on
+// this DaxConn after SetReadOnly method is called.
+type ReadOnlyDaxConn interface {
+	SetReadOnly()
+	IsWriteAttempted() bool
+}
+
+// TxnReadOnly is the function that executes logic functions in a read-only
+// transaction.
+//
+// This function works as same as Txn function, but tells DaxConn(s)
+// implementing ReadOnlyDaxConn that they are read-only, and never commits
+// DaxConn(s).
+// Instead, this function always rollbacks DaxConn(s) to release them
+// cheaply.
+// If logic functions succeed but some DaxConn(s) report that writes were
+// attempted, this function returns an errs.Err of the reason:
+// WriteIsAttemptedInReadOnlyTxn.
+//
+// Functions of TxnHook#AfterCommit are never called in a read-only
+// transaction, and functions of TxnHook#AfterRollback are called only when the
+// transaction fails.
+//
+// A read-only transaction cannot be nested in a transaction which is not
+// read-only, because DaxConn(s) already created in the outer transaction are
+// not read-only.
+// In that case, this function runs no logic function and returns an errs.Err
+// of the reason: ReadOnlyTxnIsNestedInReadWriteTxn.
+func TxnReadOnly[D any](base DaxBase, logics ...func(dax D) errs.Err) errs.Err {
+	return runTxn[D](context.Background(), base, true, logics)
+}
+
+// TxnReadOnly_ is the function that creates a runner function which runs a
+// TxnReadOnly function.
+func TxnReadOnly_[D any](base DaxBase, logics ...func(dax D) errs.Err) func() errs.Err {
+	return func() errs.Err {
+		return TxnReadOnly[D](base, logics...)
+	}
+}
+
+// TxnReadOnlyCtx is the function that executes logic functions in a
+// read-only transaction with a context.Context.
+// This function works as same as TxnReadOnly function, and the argument
+// context.Context is used in the same way as TxnCtx function.
+func TxnReadOnlyCtx[D any](ctx context.Context, base DaxBase, logics ...func(dax D) errs.Err) errs.Err {
+	return runTxn[D](ctx, base, true, logics)
+}
+
+// TxnReadOnlyCtx_ is the function that creates a runner function which runs a
+// TxnReadOnlyCtx function.
+func TxnReadOnlyCtx_[D any](ctx context.Context, base DaxBase, logics ...func(dax D) errs.Err) func() errs.Err {
+	return func() errs.Err {
+		return TxnReadOnlyCtx[D](ctx, base, logics...)
+	}
+}
+
+func (base *daxBaseImpl) checkWriteAttempted() errs.Err {
+	var names []string
+
+	for ent := base.daxConnMap.Front(); ent != nil; ent = ent.Next() {
+		roc, ok := unwrapDaxConn(ent.Value()).(ReadOnlyDaxConn)
+		if ok && roc.IsWriteAttempted() {
+			names = append(names, ent.Key())
+		}
+	}
+
+	if len(names) > 0 {
+		return errs.New(WriteIsAttemptedInReadOnlyTxn{Names: names})
+	}
+
+	return errs.Ok()
+}
@@ -0,0 +1,258 @@
+package sabi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type RoDaxSrc struct{}
+
+func (ds RoDaxSrc) Setup(ag AsyncGroup) errs.Err {
+	return errs.Ok()
+}
+func (ds RoDaxSrc) Close() {}
+func (ds RoDaxSrc) CreateDaxConn() (DaxConn, errs.Err) {
+	return &RoDaxConn{}, errs.Ok()
+}
+
+type RoDaxConn struct {
+	readOnly       bool
+	writeAttempted bool
+}
+
+func (conn *RoDaxConn) Write() {
+	if conn.readOnly {
+		conn.writeAttempted = true
+		return
+	}
+	Logs.PushBack("RoDaxConn#Write")
+}
+func (conn *RoDaxConn) SetReadOnly() {
+	Logs.PushBack("RoDaxConn#SetReadOnly")
+	conn.readOnly = true
+}
+func (conn *RoDaxConn) IsWriteAttempted() bool {
+	return conn.writeAttempted
+}
+func (conn *RoDaxConn) Commit(ag AsyncGroup) errs.Err {
+	Logs.PushBack("RoDaxConn#Commit")
+	return errs.Ok()
+}
+func (conn *RoDaxConn) IsCommitted() bool {
+	return false
+}
+func (conn *RoDaxConn) Rollback(ag AsyncGroup) {
+	Logs.PushBack("RoDaxConn#Rollback")
+}
+func (conn *RoDaxConn) ForceBack(ag AsyncGroup) {
+	Logs.PushBack("RoDaxConn#ForceBack")
+}
+func (conn *RoDaxConn) Close() {
+	Logs.PushBack("RoDaxConn#Close")
+}
+
+func TestTxnReadOnly_ok(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	AddTxnHook(newLoggingTxnHook("global"))
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("ro", RoDaxSrc{}).IfOk(base.Uses_("foo", FooDaxSrc{}))
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	err = TxnReadOnly(base, func(dax Dax) errs.Err {
+		_, err := GetDaxConn[*RoDaxConn](dax, "ro")
+		assert.True(t, err.IsOk())
+		_, err = GetDaxConn[FooDaxConn](dax, "foo")
+		assert.True(t, err.IsOk())
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "global BeforeBegin")
+	log = log.Next()
+	assert.Equal(t, log.Value, "global BeforeLogic 0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#SetReadOnly")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "global AfterLogic 0 ")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "global AfterEnd ro,foo ")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxnReadOnly_writeIsAttempted(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("ro", RoDaxSrc{})
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	err = TxnReadOnly_(base, func(dax Dax) errs.Err {
+		conn, err := GetDaxConn[*RoDaxConn](dax, "ro")
+		assert.True(t, err.IsOk())
+		conn.Write()
+		return errs.Ok()
+	})()
+	switch r := err.Reason().(type) {
+	case WriteIsAttemptedInReadOnlyTxn:
+		assert.Equal(t, r.Names, []string{"ro"})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "RoDaxConn#SetReadOnly")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxnReadOnly_notReadOnlyAfterEnd(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("ro", RoDaxSrc{})
+	assert.True(t, err.IsOk())
+
+	err = TxnReadOnlyCtx(context.Background(), base, func(dax Dax) errs.Err {
+		_, err := GetDaxConn[*RoDaxConn](dax, "ro")
+		return err
+	})
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		conn, err := GetDaxConn[*RoDaxConn](dax, "ro")
+		assert.True(t, err.IsOk())
+		conn.Write()
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "RoDaxConn#Write")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxnReadOnlyCtx_canceled(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := TxnReadOnlyCtx_(ctx, base, func(dax Dax) errs.Err {
+		return errs.Ok()
+	})()
+	assert.IsType(t, err.Reason(), TxnIsCanceled{})
+}
+
+func TestTxnReadOnly_nestedInReadWriteTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("ro", RoDaxSrc{})
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		conn, err := GetDaxConn[*RoDaxConn](dax, "ro")
+		assert.True(t, err.IsOk())
+
+		err = TxnReadOnly(base, func(dax Dax) errs.Err {
+			Logs.PushBack("run inner logic")
+			conn.Write()
+			return errs.Ok()
+		})
+		assert.IsType(t, err.Reason(), ReadOnlyTxnIsNestedInReadWriteTxn{})
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+	assert.Nil(t, base.(*daxBaseImpl).nestedTxn)
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "RoDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxnReadOnly_nestedInReadOnlyTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("ro", RoDaxSrc{})
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	err = TxnReadOnly(base, func(dax Dax) errs.Err {
+		conn, err := GetDaxConn[*RoDaxConn](dax, "ro")
+		assert.True(t, err.IsOk())
+
+		return TxnReadOnly(base, func(dax Dax) errs.Err {
+			conn.Write()
+			return errs.Ok()
+		})
+	})
+	switch r := err.Reason().(type) {
+	case WriteIsAttemptedInReadOnlyTxn:
+		assert.Equal(t, r.Names, []string{"ro"})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "RoDaxConn#SetReadOnly")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "RoDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
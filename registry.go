@@ -0,0 +1,87 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"github.com/sttk/sabi/errs"
+)
+
+// Registry is the struct type that owns global DaxSrc(s), global TxnHook(s),
+// a Tracer, a MetricsSink, and an errs.Notifier holding error handlers.
+// DaxBase(s) created with NewDaxBase method of a Registry use DaxSrc(s) and
+// TxnHook(s) of that Registry.
+//
+// The package-level functions: Uses, Setup, Close, StartApp, NewDaxBase, and
+// so on operate the default Registry, which can be got with DefaultRegistry
+// function.
+// Other Registry instances created with NewRegistry function have DaxSrc(s),
+// TxnHook(s), a Tracer, and a MetricsSink independent of the default Registry
+// and each other, so multiple applications or tests can coexist in a process.
+//
+// However, error notifications are not scoped to a Registry.
+// Since an errs.Err does not know which Registry it is created for, each
+// fixed errs.Notifier of all Registry instances is notified of every errs.Err
+// created in the process.
+type Registry struct {
+	isDaxSrcsFixed  bool
+	daxSrcEntryList daxSrcEntryList
+	txnHookList     txnHookList
+	txnGate         txnGate
+	notifier        *errs.Notifier
+	tracer          Tracer
+	metrics         MetricsSink
+}
+
+var defaultRegistry = &Registry{
+	notifier: errs.DefaultNotifier(),
+	tracer:   noopTracer{},
+	metrics:  noopMetricsSink{},
+}
+
+// DefaultRegistry is the function to get the default Registry which the
+// package-level functions operate.
+// The errs.Notifier of the default Registry is errs.DefaultNotifier().
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// NewRegistry is the function that creates a new Registry instance with a new
+// errs.Notifier.
+func NewRegistry() *Registry {
+	return &Registry{
+		notifier: errs.NewNotifier(),
+		tracer:   noopTracer{},
+		metrics:  noopMetricsSink{},
+	}
+}
+
+// Notifier is the method to get the errs.Notifier of this Registry.
+// Error handlers should be added to it before Setup method is called, because
+// its configuration is fixed at that time.
+// Handlers of this errs.Notifier receive errs.Err(s) created in the process,
+// not only ones related to this Registry.
+func (reg *Registry) Notifier() *errs.Notifier {
+	return reg.notifier
+}
+
+// Reset is the method that removes all DaxSrc(s), TxnHook(s), error
+// handlers, the Tracer, and the MetricsSink from this Registry, and releases
+// its fixed state, so that this Registry can be configured and set up again.
+// DaxSrc(s) are not closed by this method, so Close method or Shutdown method
+// should be called before this method.
+func (reg *Registry) Reset() {
+	reg.isDaxSrcsFixed = false
+	reg.daxSrcEntryList = daxSrcEntryList{}
+	reg.txnHookList = txnHookList{}
+	reg.txnGate = txnGate{}
+	reg.notifier.Reset()
+	reg.tracer = noopTracer{}
+	reg.metrics = noopMetricsSink{}
+}
+
+func (reg *Registry) fix() {
+	reg.isDaxSrcsFixed = true
+	reg.notifier.FixCfg()
+}
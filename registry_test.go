@@ -0,0 +1,153 @@
+package sabi
+
+import (
+	"container/list"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+func TestRegistry_independentOfDefault(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("foo", FooDaxSrc{})
+
+	reg := NewRegistry()
+	reg.Uses("bar", &BarDaxSrc{})
+	reg.AddTxnHook(TxnHook{
+		BeforeBegin: func() { Logs.PushBack("reg:BeforeBegin") },
+	})
+
+	err := reg.Setup()
+	assert.True(t, err.IsOk())
+	assert.True(t, reg.isDaxSrcsFixed)
+	assert.False(t, defaultRegistry.isDaxSrcsFixed)
+
+	Uses("baz", FooDaxSrc{})
+	assert.Equal(t, defaultRegistry.daxSrcEntryList.last.name, "baz")
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := dax.getDaxConn("bar")
+		assert.True(t, err.IsOk())
+		_, err = dax.getDaxConn("foo")
+		assert.IsType(t, err.Reason(), DaxSrcIsNotFound{})
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+
+	err = reg.Close()
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "BarDaxSrc#Setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "reg:BeforeBegin")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestRegistry_Reset(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	reg := NewRegistry()
+	reg.Uses("foo", FooDaxSrc{})
+
+	err := reg.Setup()
+	assert.True(t, err.IsOk())
+
+	reg.Uses("bar", &BarDaxSrc{})
+	assert.Equal(t, reg.daxSrcEntryList.last.name, "foo")
+
+	err = reg.Close()
+	assert.True(t, err.IsOk())
+
+	reg.Reset()
+	assert.False(t, reg.isDaxSrcsFixed)
+	assert.Nil(t, reg.daxSrcEntryList.head)
+
+	reg.Uses("bar", &BarDaxSrc{})
+
+	err = reg.Setup()
+	assert.True(t, err.IsOk())
+
+	err = reg.Close()
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxSrc#Setup")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestRegistry_Notifier(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	type FailToDoSomething struct{}
+
+	logs := list.New()
+
+	reg := NewRegistry()
+	reg.Notifier().AddSyncHandler(func(err errs.Err, occ errs.ErrOcc) {
+		logs.PushBack(err.ReasonName())
+	})
+
+	errs.New(FailToDoSomething{})
+	assert.Equal(t, logs.Len(), 0)
+
+	err := reg.Setup()
+	assert.True(t, err.IsOk())
+
+	errs.New(FailToDoSomething{})
+	assert.Equal(t, logs.Len(), 1)
+	assert.Equal(t, logs.Back().Value, "FailToDoSomething")
+
+	reg.Reset()
+
+	errs.New(FailToDoSomething{})
+	assert.Equal(t, logs.Len(), 1)
+}
+
+func TestRegistry_Shutdown(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	reg := NewRegistry()
+	reg.Uses("foo", FooDaxSrc{})
+
+	err := reg.Setup()
+	assert.True(t, err.IsOk())
+
+	err = reg.Shutdown(context.Background())
+	assert.True(t, err.IsOk())
+
+	base := reg.NewDaxBase()
+	err = Txn(base, func(dax Dax) errs.Err { return errs.Ok() })
+	assert.IsType(t, err.Reason(), AppIsShuttingDown{})
+
+	base = NewDaxBase()
+	err = Txn(base, func(dax Dax) errs.Err { return errs.Ok() })
+	assert.True(t, err.IsOk())
+}
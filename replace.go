@@ -0,0 +1,186 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type /* error reasons */ (
+	// GlobalDaxSrcsAreNotSetup is the error reason which indicates that a
+	// global DaxSrc is tried to be replaced before global DaxSrc(s) are set up.
+	GlobalDaxSrcsAreNotSetup struct{}
+
+	// FailToReplaceDaxSrc is the error reason which indicates that a new
+	// DaxSrc failed to set up, so a global DaxSrc was not replaced.
+	// The field Name is the registered name of the DaxSrc.
+	FailToReplaceDaxSrc struct {
+		Name string
+	}
+
+	// ReplacedDaxSrcIsNotDrained is the error reason which indicates that
+	// DaxConn(s) created by a replaced DaxSrc were not closed before the
+	// context.Context of Replace was done.
+	// The replaced DaxSrc is closed after those DaxConn(s) are closed.
+	// The field Name is the registered name of the DaxSrc.
+	ReplacedDaxSrcIsNotDrained struct {
+		Name string
+	}
+
+	// FailToCloseReplacedDaxSrc is the error reason which indicates that a
+	// replaced DaxSrc failed to close.
+	// The field Name is the registered name of the DaxSrc.
+	FailToCloseReplacedDaxSrc struct {
+		Name string
+	}
+)
+
+// daxSrcUsage counts DaxConn(s) which are created by a DaxSrc and not closed
+// yet, so that the DaxSrc can be closed after all of them are closed.
+type daxSrcUsage struct {
+	mutex   sync.Mutex
+	count   int
+	retired bool
+	drained chan struct{}
+}
+
+func newDaxSrcUsage() *daxSrcUsage {
+	return &daxSrcUsage{drained: make(chan struct{})}
+}
+
+func (usage *daxSrcUsage) acquire() {
+	usage.mutex.Lock()
+	defer usage.mutex.Unlock()
+	usage.count++
+}
+
+func (usage *daxSrcUsage) release() {
+	usage.mutex.Lock()
+	defer usage.mutex.Unlock()
+	usage.count--
+	if usage.retired && usage.count == 0 {
+		close(usage.drained)
+	}
+}
+
+func (usage *daxSrcUsage) retire() <-chan struct{} {
+	usage.mutex.Lock()
+	defer usage.mutex.Unlock()
+	usage.retired = true
+	if usage.count == 0 {
+		close(usage.drained)
+	}
+	return usage.drained
+}
+
+func (ent *daxSrcEntry) daxSrc() DaxSrc {
+	ent.mutex.Lock()
+	defer ent.mutex.Unlock()
+	return ent.ds
+}
+
+func (ent *daxSrcEntry) acquire() (DaxSrc, *daxSrcUsage) {
+	ent.mutex.Lock()
+	defer ent.mutex.Unlock()
+	if ent.usage == nil {
+		ent.usage = newDaxSrcUsage()
+	}
+	ent.usage.acquire()
+	return ent.ds, ent.usage
+}
+
+func (ent *daxSrcEntry) swap(ds DaxSrc) (DaxSrc, <-chan struct{}) {
+	ent.mutex.Lock()
+	defer ent.mutex.Unlock()
+	old, usage := ent.ds, ent.usage
+	ent.ds, ent.usage = ds, newDaxSrcUsage()
+	if usage == nil {
+		usage = newDaxSrcUsage()
+	}
+	return old, usage.retire()
+}
+
+// Replace is the function that replaces a global DaxSrc of the default
+// Registry with the argument DaxSrc at runtime.
+//
+// This function sets up the new DaxSrc first, and if it succeeds, swaps the
+// global DaxSrc registered with the argument name atomically.
+// DaxConn(s) created after the swap are created by the new DaxSrc, and
+// DaxConn(s) already created by the old DaxSrc are used until the end of their
+// transactions.
+// Then, this function waits for those DaxConn(s) to be closed, and closes the
+// old DaxSrc.
+//
+// If the new DaxSrc fails to set up, this function closes it and returns an
+// errs.Err of the reason: FailToReplaceDaxSrc, without swapping.
+// If the argument context.Context is done before DaxConn(s) of the old DaxSrc
+// are closed, this function returns an errs.Err of the reason:
+// ReplacedDaxSrcIsNotDrained, and the old DaxSrc is closed later when they are
+// closed.
+// If the old DaxSrc fails to close, this function returns an errs.Err of the
+// reason: FailToCloseReplacedDaxSrc.
+//
+// This function can be called only after Setup function or the first
+// creation of a DaxBase, otherwise this function returns an errs.Err of the
+// reason: GlobalDaxSrcsAreNotSetup.
+func Replace(ctx context.Context, name string, ds DaxSrc) errs.Err {
+	return defaultRegistry.Replace(ctx, name, ds)
+}
+
+// Replace is the method that replaces a global DaxSrc of this Registry with
+// the argument DaxSrc at runtime.
+// See Replace function for details.
+func (reg *Registry) Replace(ctx context.Context, name string, ds DaxSrc) errs.Err {
+	if !reg.isDaxSrcsFixed {
+		return errs.New(GlobalDaxSrcsAreNotSetup{})
+	}
+
+	var ent *daxSrcEntry
+	for e := reg.daxSrcEntryList.head; e != nil; e = e.next {
+		if e.name == name {
+			ent = e
+			break
+		}
+	}
+	if ent == nil {
+		return errs.New(DaxSrcIsNotFound{Name: name})
+	}
+
+	var ag asyncGroupAsync[string]
+	ag.ctx = ctx
+	ag.name = name
+
+	err := ds.Setup(&ag)
+	ag.wait()
+	if err.IsOk() && ag.hasErr() {
+		err = ag.makeErrs()[name]
+	}
+	if err.IsNotOk() {
+		closeWithErr(ds)
+		return errs.New(FailToReplaceDaxSrc{Name: name}, err)
+	}
+
+	old, drained := ent.swap(ds)
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		go func() {
+			<-drained
+			closeWithErr(old)
+		}()
+		return errs.New(ReplacedDaxSrcIsNotDrained{Name: name}, ctx.Err())
+	}
+
+	err = closeWithErr(old)
+	if err.IsNotOk() {
+		return errs.New(FailToCloseReplacedDaxSrc{Name: name}, err)
+	}
+
+	return errs.Ok()
+}
@@ -0,0 +1,165 @@
+package sabi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+func TestReplace_notSetup(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("database", DepDaxSrc{name: "old"})
+
+	err := Replace(context.Background(), "database", DepDaxSrc{name: "new"})
+	assert.IsType(t, err.Reason(), GlobalDaxSrcsAreNotSetup{})
+	assert.Equal(t, len(DepLogs.Values()), 0)
+}
+
+func TestReplace_notFound(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("database", DepDaxSrc{name: "old"})
+	assert.True(t, Setup().IsOk())
+	defer Close()
+
+	err := Replace(context.Background(), "file", DepDaxSrc{name: "new"})
+	switch r := err.Reason().(type) {
+	case DaxSrcIsNotFound:
+		assert.Equal(t, r.Name, "file")
+	default:
+		assert.Fail(t, err.Error())
+	}
+}
+
+func TestReplace_failToSetup(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("database", DepDaxSrc{name: "old"})
+	assert.True(t, Setup().IsOk())
+
+	err := Replace(context.Background(), "database",
+		DepDaxSrc{name: "new", fail: true})
+	switch r := err.Reason().(type) {
+	case FailToReplaceDaxSrc:
+		assert.Equal(t, r.Name, "database")
+		assert.IsType(t, err.Cause().(errs.Err).Reason(), FailToSetupDepDaxSrc{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	base := NewDaxBase()
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := dax.getDaxConn("database")
+		return err
+	})
+	assert.True(t, err.IsOk())
+
+	assert.True(t, Close().IsOk())
+
+	assert.Equal(t, DepLogs.Values(), []string{
+		"old#Setup",
+		"new#Close",
+		"old#CreateDaxConn",
+		"DepDaxConn#Commit",
+		"DepDaxConn#Close",
+		"old#Close",
+	})
+}
+
+func TestReplace_waitForInFlightTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("database", DepDaxSrc{name: "old"})
+	assert.True(t, Setup().IsOk())
+
+	acquired := make(chan struct{})
+	replaced := make(chan errs.Err)
+
+	go func() {
+		<-acquired
+		replaced <- Replace(context.Background(), "database", DepDaxSrc{name: "new"})
+	}()
+
+	base := NewDaxBase()
+	err := Txn(base, func(dax Dax) errs.Err {
+		_, err := dax.getDaxConn("database")
+		close(acquired)
+		time.Sleep(20 * time.Millisecond)
+		DepLogs.PushBack("end of logic")
+		return err
+	})
+	assert.True(t, err.IsOk())
+
+	err = <-replaced
+	assert.True(t, err.IsOk())
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := dax.getDaxConn("database")
+		return err
+	})
+	assert.True(t, err.IsOk())
+
+	assert.True(t, Close().IsOk())
+
+	assert.Equal(t, DepLogs.Values(), []string{
+		"old#Setup",
+		"old#CreateDaxConn",
+		"new#Setup",
+		"end of logic",
+		"DepDaxConn#Commit",
+		"DepDaxConn#Close",
+		"old#Close",
+		"new#CreateDaxConn",
+		"DepDaxConn#Commit",
+		"DepDaxConn#Close",
+		"new#Close",
+	})
+}
+
+func TestReplace_notDrained(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("database", DepDaxSrc{name: "old"})
+	assert.True(t, Setup().IsOk())
+
+	base := NewDaxBase()
+	err := Txn(base, func(dax Dax) errs.Err {
+		_, err := dax.getDaxConn("database")
+		assert.True(t, err.IsOk())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err = Replace(ctx, "database", DepDaxSrc{name: "new"})
+		switch r := err.Reason().(type) {
+		case ReplacedDaxSrcIsNotDrained:
+			assert.Equal(t, r.Name, "database")
+			assert.Equal(t, err.Cause(), context.DeadlineExceeded)
+		default:
+			assert.Fail(t, err.Error())
+		}
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Equal(t, DepLogs.Values(), []string{
+		"old#Setup",
+		"old#CreateDaxConn",
+		"new#Setup",
+		"DepDaxConn#Commit",
+		"DepDaxConn#Close",
+		"old#Close",
+	})
+}
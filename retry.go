@@ -0,0 +1,162 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sttk/sabi/errs"
+)
+
+// RetryPolicy is the struct type that configures retries of a transaction by
+// TxnWithRetry function.
+//
+// MaxAttempts is the maximum number of attempts including the first one.
+// If it is less than 1, a transaction is attempted only once.
+// Backoff is the function to get a waiting time before the next attempt with
+// the number of attempts already done.
+// If it is nil, the next attempt starts without waiting.
+// IsRetryable is the function to decide whether the errs.Err of a failed
+// attempt is retryable.
+// If it is nil, no error is retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     Backoff
+	IsRetryable func(err errs.Err) bool
+}
+
+// Backoff is the function type to get a waiting time before the next attempt
+// with the number of attempts already done.
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff is the function that creates a Backoff which always returns
+// the argument duration.
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff is the function that creates a Backoff of which waiting
+// time starts with the argument initial duration and doubles at each attempt
+// up to the argument max duration.
+// The argument jitter is a ratio from 0.0 to 1.0, by which a waiting time is
+// reduced randomly to avoid that concurrent transactions retry at the same
+// time.
+func ExponentialBackoff(initial, max time.Duration, jitter float64) Backoff {
+	return func(attempt int) time.Duration {
+		d := initial
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		if jitter > 0 {
+			d -= time.Duration(jitterRand.float64() * jitter * float64(d))
+		}
+		return d
+	}
+}
+
+// jitterRand is the random source for jitters of backoffs.
+// This is seeded at the start of the process, because the global source of
+// math/rand is deterministically seeded before Go 1.20.
+var jitterRand = lockedRand{
+	rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+}
+
+type lockedRand struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func (r *lockedRand) float64() float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rand.Float64()
+}
+
+// RetryIfReasonIs is the function that creates a function for
+// RetryPolicy#IsRetryable, which decides that an errs.Err is retryable if its
+// reason or a reason of any of its causes has the same type with one of the
+// argument reasons.
+// A value and a pointer of a reason struct type are regarded as the same
+// type.
+func RetryIfReasonIs(reasons ...any) func(err errs.Err) bool {
+	targets := make([]errs.Err, len(reasons))
+	for i, r := range reasons {
+		targets[i] = errs.Target(r)
+	}
+
+	return func(err errs.Err) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TxnWithRetry is the function that executes logic functions in a
+// transaction, and retries the transaction according to the argument
+// RetryPolicy.
+//
+// Each attempt is processed as same as Txn function, so all updates of a
+// failed attempt are rollbacked and the attempt is ended before the next
+// attempt runs all logic functions again.
+// If an attempt fails with an errs.Err which is not retryable, or the number of
+// attempts reaches RetryPolicy#MaxAttempts, this function returns the errs.Err
+// of the last attempt.
+func TxnWithRetry[D any](base DaxBase, policy RetryPolicy, logics ...func(dax D) errs.Err) errs.Err {
+	return TxnCtxWithRetry[D](context.Background(), base, policy, logics...)
+}
+
+// TxnWithRetry_ is the function that creates a runner function which runs a
+// TxnWithRetry function.
+func TxnWithRetry_[D any](base DaxBase, policy RetryPolicy, logics ...func(dax D) errs.Err) func() errs.Err {
+	return func() errs.Err {
+		return TxnWithRetry[D](base, policy, logics...)
+	}
+}
+
+// TxnCtxWithRetry is the function that does the same as TxnWithRetry function
+// but executes each attempt with TxnCtx function.
+// If the argument context.Context is canceled while waiting for the next
+// attempt, this function returns an errs.Err of the reason: TxnIsCanceled.
+func TxnCtxWithRetry[D any](ctx context.Context, base DaxBase, policy RetryPolicy, logics ...func(dax D) errs.Err) errs.Err {
+	for attempt := 1; ; attempt++ {
+		err := TxnCtx[D](ctx, base, logics...)
+		if err.IsOk() || attempt >= policy.MaxAttempts {
+			return err
+		}
+		if policy.IsRetryable == nil || !policy.IsRetryable(err) {
+			return err
+		}
+
+		if policy.Backoff != nil {
+			timer := time.NewTimer(policy.Backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errs.New(TxnIsCanceled{}, ctx.Err())
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// TxnCtxWithRetry_ is the function that creates a runner function which runs
+// a TxnCtxWithRetry function.
+func TxnCtxWithRetry_[D any](ctx context.Context, base DaxBase, policy RetryPolicy, logics ...func(dax D) errs.Err) func() errs.Err {
+	return func() errs.Err {
+		return TxnCtxWithRetry[D](ctx, base, policy, logics...)
+	}
+}
@@ -0,0 +1,217 @@
+package sabi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type (
+	SerializationFailure struct{}
+	InvalidInput         struct{}
+)
+
+func TestConstantBackoff(t *testing.T) {
+	backoff := ConstantBackoff(10 * time.Millisecond)
+	assert.Equal(t, backoff(1), 10*time.Millisecond)
+	assert.Equal(t, backoff(5), 10*time.Millisecond)
+}
+
+func TestExponentialBackoff_noJitter(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond, 0)
+	assert.Equal(t, backoff(1), 10*time.Millisecond)
+	assert.Equal(t, backoff(2), 20*time.Millisecond)
+	assert.Equal(t, backoff(3), 40*time.Millisecond)
+	assert.Equal(t, backoff(4), 50*time.Millisecond)
+	assert.Equal(t, backoff(100), 50*time.Millisecond)
+}
+
+func TestExponentialBackoff_withJitter(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond, 0.5)
+	for i := 0; i < 100; i++ {
+		d := backoff(2)
+		assert.True(t, d > 10*time.Millisecond)
+		assert.True(t, d <= 20*time.Millisecond)
+	}
+}
+
+func TestRetryIfReasonIs(t *testing.T) {
+	isRetryable := RetryIfReasonIs(SerializationFailure{}, &InvalidInput{})
+
+	assert.True(t, isRetryable(errs.New(SerializationFailure{})))
+	assert.True(t, isRetryable(errs.New(&SerializationFailure{})))
+	assert.True(t, isRetryable(errs.New(&InvalidInput{})))
+	assert.True(t, isRetryable(errs.New(InvalidInput{})))
+	assert.False(t, isRetryable(errs.New(FailToRunLogic{})))
+
+	err := errs.New(FailToCommitDaxConn{}, errs.New(SerializationFailure{}))
+	assert.True(t, isRetryable(err))
+
+	err = errs.New(FailToCommitDaxConn{},
+		errs.New(FailToRunLogic{}), errs.New(SerializationFailure{}))
+	assert.True(t, isRetryable(err))
+
+	err = errs.New(FailToCommitDaxConn{}, errs.New(FailToRunLogic{}))
+	assert.False(t, isRetryable(err))
+
+	assert.False(t, isRetryable(errs.Ok()))
+}
+
+func TestTxnWithRetry_succeedAtFirst(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		IsRetryable: RetryIfReasonIs(SerializationFailure{}),
+	}
+
+	count := 0
+	err := TxnWithRetry(base, policy, func(dax Dax) errs.Err {
+		count++
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+	assert.Equal(t, count, 1)
+}
+
+func TestTxnWithRetry_succeedAfterRetries(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	func() {
+		base := NewDaxBase()
+		defer base.Close()
+
+		err := base.Uses("database", FooDaxSrc{})
+		assert.True(t, err.IsOk())
+
+		policy := RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     ConstantBackoff(time.Millisecond),
+			IsRetryable: RetryIfReasonIs(SerializationFailure{}),
+		}
+
+		count := 0
+		err = TxnWithRetry(base, policy, func(dax Dax) errs.Err {
+			_, err := GetDaxConn[FooDaxConn](dax, "database")
+			assert.True(t, err.IsOk())
+			count++
+			if count < 3 {
+				return errs.New(SerializationFailure{})
+			}
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+		assert.Equal(t, count, 3)
+	}()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "FooDaxSrc#Setup")
+	for i := 0; i < 2; i++ {
+		log = log.Next()
+		assert.Equal(t, log.Value, "FooDaxSrc#CreateDaxConn")
+		log = log.Next()
+		assert.Equal(t, log.Value, "FooDaxConn#Rollback")
+		log = log.Next()
+		assert.Equal(t, log.Value, "FooDaxConn#Close")
+	}
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxnWithRetry_exceedMaxAttempts(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		IsRetryable: RetryIfReasonIs(SerializationFailure{}),
+	}
+
+	count := 0
+	err := TxnWithRetry(base, policy, func(dax Dax) errs.Err {
+		count++
+		return errs.New(SerializationFailure{})
+	})
+	assert.IsType(t, err.Reason(), SerializationFailure{})
+	assert.Equal(t, count, 3)
+}
+
+func TestTxnWithRetry_notRetryable(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		IsRetryable: RetryIfReasonIs(SerializationFailure{}),
+	}
+
+	count := 0
+	err := TxnWithRetry(base, policy, func(dax Dax) errs.Err {
+		count++
+		return errs.New(InvalidInput{})
+	})
+	assert.IsType(t, err.Reason(), InvalidInput{})
+	assert.Equal(t, count, 1)
+
+	count = 0
+	err = TxnWithRetry_(base, RetryPolicy{MaxAttempts: 3}, func(dax Dax) errs.Err {
+		count++
+		return errs.New(SerializationFailure{})
+	})()
+	assert.IsType(t, err.Reason(), SerializationFailure{})
+	assert.Equal(t, count, 1)
+}
+
+func TestTxnCtxWithRetry_canceledWhileWaiting(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     ConstantBackoff(time.Hour),
+		IsRetryable: RetryIfReasonIs(SerializationFailure{}),
+	}
+
+	count := 0
+	err := TxnCtxWithRetry_(ctx, base, policy, func(dax Dax) errs.Err {
+		count++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return errs.New(SerializationFailure{})
+	})()
+	switch err.Reason().(type) {
+	case TxnIsCanceled:
+		assert.Equal(t, err.Cause(), context.Canceled)
+	default:
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, count, 1)
+}
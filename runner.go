@@ -48,7 +48,8 @@ func Para(runners ...func() errs.Err) errs.Err {
 	ag.wait()
 
 	if ag.hasErr() {
-		return errs.New(FailToRunInParallel{Errors: ag.makeErrs()})
+		errMap := ag.makeErrs()
+		return errs.New(FailToRunInParallel{Errors: errMap}, causesOf(errMap)...)
 	}
 
 	return errs.Ok()
@@ -0,0 +1,306 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+// sabitest is the package that provides mock DaxSrc and DaxConn, and a
+// Tracer recording spans in memory, for testing applications and libraries
+// using sabi.
+//
+// DaxSrc and DaxConn of this package record calls of their methods to a
+// Recorder, and can be scripted to fail or to run asynchronously.
+//
+//	rec := sabitest.NewRecorder()
+//	sabi.Uses("database", sabitest.NewDaxSrc("database", rec).
+//	    WithCommitErr(errs.New(FailToCommit{})))
+//
+//	...
+//
+//	rec.AssertCalls(t,
+//	    "database#Setup",
+//	    "database#CreateDaxConn",
+//	    "database#Commit",
+//	    "database#Rollback",
+//	    "database#Close(DaxConn)",
+//	)
+package sabitest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+)
+
+// Recorder is the struct type that records calls of methods of DaxSrc(s) and
+// DaxConn(s) in order.
+// A Recorder is safe for concurrent use.
+type Recorder struct {
+	mutex sync.Mutex
+	calls []string
+}
+
+// NewRecorder is the function that creates a new Recorder instance.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (rec *Recorder) record(name, method string) {
+	if rec == nil {
+		return
+	}
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.calls = append(rec.calls, name+"#"+method)
+}
+
+// Calls is the method to get the recorded calls in order.
+// Each call is expressed as "<name>#<method>", and calls of Close method of
+// DaxConn are expressed as "<name>#Close(DaxConn)" to be distinguished from
+// those of DaxSrc.
+func (rec *Recorder) Calls() []string {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	calls := make([]string, len(rec.calls))
+	copy(calls, rec.calls)
+	return calls
+}
+
+// Reset is the method to clear the recorded calls.
+func (rec *Recorder) Reset() {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.calls = nil
+}
+
+// AssertCalls is the method to check that the recorded calls are equal to
+// the argument calls.
+// If they are not equal, this method reports an error to the argument
+// testing.TB and returns false.
+func (rec *Recorder) AssertCalls(t testing.TB, calls ...string) bool {
+	t.Helper()
+
+	actual := rec.Calls()
+	if len(actual) == len(calls) {
+		equal := true
+		for i := range calls {
+			if actual[i] != calls[i] {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return true
+		}
+	}
+
+	t.Errorf("Recorded calls are not equal:\n"+
+		"\texpected: %s\n\tactual  : %s", format(calls), format(actual))
+	return false
+}
+
+// AssertOrder is the method to check that the argument calls are recorded in
+// this order, though other calls may be recorded between them.
+// If they are not, this method reports an error to the argument testing.TB
+// and returns false.
+func (rec *Recorder) AssertOrder(t testing.TB, calls ...string) bool {
+	t.Helper()
+
+	actual := rec.Calls()
+	i := 0
+	for _, call := range actual {
+		if i < len(calls) && call == calls[i] {
+			i++
+		}
+	}
+	if i == len(calls) {
+		return true
+	}
+
+	t.Errorf("Recorded calls are not in order:\n"+
+		"\texpected: %s\n\tactual  : %s", format(calls), format(actual))
+	return false
+}
+
+func format(calls []string) string {
+	return "[" + strings.Join(calls, ", ") + "]"
+}
+
+// DaxSrc is the struct type of a mock sabi.DaxSrc.
+// This DaxSrc records calls of its methods and of methods of DaxConn(s)
+// created by it to a Recorder.
+type DaxSrc struct {
+	name             string
+	rec              *Recorder
+	asyncSetup       bool
+	setupErr         errs.Err
+	createDaxConnErr errs.Err
+	closeErr         errs.Err
+	conn             daxConnScript
+}
+
+type daxConnScript struct {
+	asyncCommit bool
+	commitErr   errs.Err
+	closeErr    errs.Err
+}
+
+// NewDaxSrc is the function that creates a new DaxSrc instance.
+// The argument name is used to express calls in the argument Recorder, and is
+// usually the same as the name with which this DaxSrc is registered.
+func NewDaxSrc(name string, rec *Recorder) *DaxSrc {
+	return &DaxSrc{name: name, rec: rec}
+}
+
+// WithAsyncSetup is the method to make Setup method of this DaxSrc run
+// asynchronously with a sabi.AsyncGroup.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithAsyncSetup() *DaxSrc {
+	ds.asyncSetup = true
+	return ds
+}
+
+// WithSetupErr is the method to make Setup method of this DaxSrc fail with the
+// argument errs.Err.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithSetupErr(err errs.Err) *DaxSrc {
+	ds.setupErr = err
+	return ds
+}
+
+// WithCreateDaxConnErr is the method to make CreateDaxConn method of this
+// DaxSrc fail with the argument errs.Err.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithCreateDaxConnErr(err errs.Err) *DaxSrc {
+	ds.createDaxConnErr = err
+	return ds
+}
+
+// WithCloseErr is the method to make CloseWithErr method of this DaxSrc
+// return the argument errs.Err.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithCloseErr(err errs.Err) *DaxSrc {
+	ds.closeErr = err
+	return ds
+}
+
+// WithAsyncCommit is the method to make Commit method of DaxConn(s) created by
+// this DaxSrc run asynchronously with a sabi.AsyncGroup.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithAsyncCommit() *DaxSrc {
+	ds.conn.asyncCommit = true
+	return ds
+}
+
+// WithCommitErr is the method to make Commit method of DaxConn(s) created by
+// this DaxSrc fail with the argument errs.Err.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithCommitErr(err errs.Err) *DaxSrc {
+	ds.conn.commitErr = err
+	return ds
+}
+
+// WithDaxConnCloseErr is the method to make CloseWithErr method of DaxConn(s)
+// created by this DaxSrc return the argument errs.Err.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithDaxConnCloseErr(err errs.Err) *DaxSrc {
+	ds.conn.closeErr = err
+	return ds
+}
+
+// Setup is the method to record its call, and returns the scripted errs.Err.
+func (ds *DaxSrc) Setup(ag sabi.AsyncGroup) errs.Err {
+	if ds.asyncSetup {
+		ag.Add(func() errs.Err {
+			ds.rec.record(ds.name, "Setup")
+			return ds.setupErr
+		})
+		return errs.Ok()
+	}
+	ds.rec.record(ds.name, "Setup")
+	return ds.setupErr
+}
+
+// Close is the method to record its call.
+func (ds *DaxSrc) Close() {
+	ds.CloseWithErr()
+}
+
+// CloseWithErr is the method to record its call, and returns the scripted
+// errs.Err.
+func (ds *DaxSrc) CloseWithErr() errs.Err {
+	ds.rec.record(ds.name, "Close")
+	return ds.closeErr
+}
+
+// CreateDaxConn is the method to record its call, and creates a DaxConn
+// instance or returns the scripted errs.Err.
+func (ds *DaxSrc) CreateDaxConn() (sabi.DaxConn, errs.Err) {
+	ds.rec.record(ds.name, "CreateDaxConn")
+	if ds.createDaxConnErr.IsNotOk() {
+		return nil, ds.createDaxConnErr
+	}
+	return &DaxConn{name: ds.name, rec: ds.rec, script: ds.conn}, errs.Ok()
+}
+
+// DaxConn is the struct type of a mock sabi.DaxConn.
+// This DaxConn records calls of its methods to the Recorder of the DaxSrc
+// which created it.
+type DaxConn struct {
+	name      string
+	rec       *Recorder
+	script    daxConnScript
+	mutex     sync.Mutex
+	committed bool
+}
+
+// Commit is the method to record its call, and returns the scripted errs.Err.
+func (conn *DaxConn) Commit(ag sabi.AsyncGroup) errs.Err {
+	commit := func() errs.Err {
+		conn.rec.record(conn.name, "Commit")
+		if conn.script.commitErr.IsNotOk() {
+			return conn.script.commitErr
+		}
+		conn.mutex.Lock()
+		defer conn.mutex.Unlock()
+		conn.committed = true
+		return errs.Ok()
+	}
+
+	if conn.script.asyncCommit {
+		ag.Add(commit)
+		return errs.Ok()
+	}
+	return commit()
+}
+
+// IsCommitted is the method to check whether Commit method of this DaxConn
+// succeeded.
+func (conn *DaxConn) IsCommitted() bool {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	return conn.committed
+}
+
+// Rollback is the method to record its call.
+func (conn *DaxConn) Rollback(ag sabi.AsyncGroup) {
+	conn.rec.record(conn.name, "Rollback")
+}
+
+// ForceBack is the method to record its call.
+func (conn *DaxConn) ForceBack(ag sabi.AsyncGroup) {
+	conn.rec.record(conn.name, "ForceBack")
+}
+
+// Close is the method to record its call.
+func (conn *DaxConn) Close() {
+	conn.CloseWithErr()
+}
+
+// CloseWithErr is the method to record its call, and returns the scripted
+// errs.Err.
+func (conn *DaxConn) CloseWithErr() errs.Err {
+	conn.rec.record(conn.name, "Close(DaxConn)")
+	return conn.script.closeErr
+}
@@ -0,0 +1,194 @@
+package sabitest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+)
+
+type (
+	FailToSetup  struct{}
+	FailToCreate struct{}
+	FailToCommit struct{}
+	FailToClose  struct{}
+	FailToDo     struct{}
+)
+
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder_AssertCalls(t *testing.T) {
+	rec := NewRecorder()
+	rec.record("foo", "Setup")
+	rec.record("bar", "Setup")
+
+	assert.True(t, rec.AssertCalls(t, "foo#Setup", "bar#Setup"))
+
+	ft := &fakeT{}
+	assert.False(t, rec.AssertCalls(ft, "foo#Setup"))
+	assert.False(t, rec.AssertCalls(ft, "bar#Setup", "foo#Setup"))
+	assert.Equal(t, len(ft.errors), 2)
+	assert.Equal(t, ft.errors[0], "Recorded calls are not equal:\n"+
+		"\texpected: [foo#Setup]\n\tactual  : [foo#Setup, bar#Setup]")
+
+	rec.Reset()
+	assert.Equal(t, len(rec.Calls()), 0)
+	assert.True(t, rec.AssertCalls(t))
+}
+
+func TestRecorder_AssertOrder(t *testing.T) {
+	rec := NewRecorder()
+	rec.record("foo", "Setup")
+	rec.record("bar", "Setup")
+	rec.record("baz", "Setup")
+
+	assert.True(t, rec.AssertOrder(t, "foo#Setup", "baz#Setup"))
+
+	ft := &fakeT{}
+	assert.False(t, rec.AssertOrder(ft, "baz#Setup", "foo#Setup"))
+	assert.Equal(t, len(ft.errors), 1)
+}
+
+func TestDaxSrc_Txn_commit(t *testing.T) {
+	rec := NewRecorder()
+
+	reg := sabi.NewRegistry()
+	reg.Uses("foo", NewDaxSrc("foo", rec))
+	reg.Uses("bar", NewDaxSrc("bar", rec).WithAsyncSetup().WithAsyncCommit())
+
+	err := reg.StartApp(func() errs.Err {
+		base := reg.NewDaxBase()
+		defer base.Close()
+
+		return sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+			conn, err := sabi.GetDaxConn[*DaxConn](dax, "foo")
+			assert.True(t, err.IsOk())
+			assert.False(t, conn.IsCommitted())
+			_, err = sabi.GetDaxConn[*DaxConn](dax, "bar")
+			return err
+		})
+	})
+	assert.True(t, err.IsOk())
+
+	rec.AssertCalls(t,
+		"foo#Setup",
+		"bar#Setup",
+		"foo#CreateDaxConn",
+		"bar#CreateDaxConn",
+		"foo#Commit",
+		"bar#Commit",
+		"foo#Close(DaxConn)",
+		"bar#Close(DaxConn)",
+		"bar#Close",
+		"foo#Close",
+	)
+}
+
+func TestDaxSrc_Txn_failToCommit(t *testing.T) {
+	rec := NewRecorder()
+
+	reg := sabi.NewRegistry()
+	reg.Uses("foo", NewDaxSrc("foo", rec))
+	reg.Uses("bar", NewDaxSrc("bar", rec).WithCommitErr(errs.New(FailToCommit{})))
+
+	err := reg.Setup()
+	assert.True(t, err.IsOk())
+	defer reg.Close()
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err = sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		_, err := sabi.GetDaxConn[*DaxConn](dax, "foo")
+		if err.IsNotOk() {
+			return err
+		}
+		_, err = sabi.GetDaxConn[*DaxConn](dax, "bar")
+		return err
+	})
+	switch r := err.Reason().(type) {
+	case sabi.FailToCommitDaxConn:
+		assert.IsType(t, r.Errors["bar"].Reason(), FailToCommit{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	rec.AssertCalls(t,
+		"foo#Setup",
+		"bar#Setup",
+		"foo#CreateDaxConn",
+		"bar#CreateDaxConn",
+		"foo#Commit",
+		"bar#Commit",
+		"foo#ForceBack",
+		"bar#Rollback",
+		"foo#Close(DaxConn)",
+		"bar#Close(DaxConn)",
+	)
+}
+
+func TestDaxSrc_Txn_logicError(t *testing.T) {
+	rec := NewRecorder()
+
+	reg := sabi.NewRegistry()
+	reg.Uses("foo", NewDaxSrc("foo", rec).WithDaxConnCloseErr(errs.New(FailToClose{})))
+	assert.True(t, reg.Setup().IsOk())
+
+	base := reg.NewDaxBase()
+
+	err := sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		_, err := sabi.GetDaxConn[*DaxConn](dax, "foo")
+		assert.True(t, err.IsOk())
+		return errs.New(FailToDo{})
+	})
+	assert.IsType(t, err.Reason(), FailToDo{})
+
+	rec.AssertOrder(t, "foo#CreateDaxConn", "foo#Rollback", "foo#Close(DaxConn)")
+}
+
+func TestDaxSrc_scriptedErrors(t *testing.T) {
+	rec := NewRecorder()
+
+	reg := sabi.NewRegistry()
+	reg.Uses("foo", NewDaxSrc("foo", rec).WithCloseErr(errs.New(FailToClose{})))
+	reg.Uses("bar", NewDaxSrc("bar", rec).
+		WithAsyncSetup().WithSetupErr(errs.New(FailToSetup{})))
+
+	err := reg.Setup()
+	switch r := err.Reason().(type) {
+	case sabi.FailToSetupGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["bar"].Reason(), FailToSetup{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	rec.AssertCalls(t, "foo#Setup", "bar#Setup", "bar#Close", "foo#Close")
+
+	rec.Reset()
+
+	reg = sabi.NewRegistry()
+	reg.Uses("foo", NewDaxSrc("foo", rec).WithCreateDaxConnErr(errs.New(FailToCreate{})))
+	assert.True(t, reg.Setup().IsOk())
+
+	err = sabi.Txn(reg.NewDaxBase(), func(dax sabi.Dax) errs.Err {
+		_, err := sabi.GetDaxConn[*DaxConn](dax, "foo")
+		return err
+	})
+	assert.IsType(t, err.Reason(), sabi.FailToCreateDaxConn{})
+	assert.IsType(t, err.Cause().(errs.Err).Reason(), FailToCreate{})
+
+	rec.AssertCalls(t, "foo#Setup", "foo#CreateDaxConn")
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabitest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+)
+
+// RecordedSpan is the struct type of a span recorded by a Tracer.
+// ID is the sequential number of spans from 1 in order of their starts, and
+// ParentID is the ID of the parent span, or 0 if the span is a root.
+// Err is the errs.Err with which the span ended, and Reason is the reason
+// name of it, which is empty if the span ended successfully.
+type RecordedSpan struct {
+	ID       int
+	ParentID int
+	Name     string
+	Attrs    map[string]any
+	Start    time.Time
+	End      time.Time
+	Ended    bool
+	Err      errs.Err
+	Reason   string
+}
+
+// Duration is the method to get the time taken from the start to the end of
+// this span.
+// If this span is not ended, this method returns 0.
+func (s RecordedSpan) Duration() time.Duration {
+	if !s.Ended {
+		return 0
+	}
+	return s.End.Sub(s.Start)
+}
+
+// Tracer is the struct type that implements sabi.Tracer and records spans in
+// memory.
+// A Tracer is safe for concurrent use.
+//
+//	tracer := sabitest.NewTracer()
+//	sabi.SetTracer(tracer)
+//
+//	...
+//
+//	for _, span := range tracer.Spans() {
+//	    fmt.Println(span.Name, span.Attrs, span.Reason, span.Duration())
+//	}
+type Tracer struct {
+	mutex sync.Mutex
+	spans []*RecordedSpan
+}
+
+// NewTracer is the function that creates a new Tracer instance.
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+type spanIDKey struct{}
+
+// Start is the method to start and record a span.
+func (tr *Tracer) Start(ctx context.Context, name string) (context.Context, sabi.Span) {
+	parentID, _ := ctx.Value(spanIDKey{}).(int)
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	s := &RecordedSpan{
+		ID:       len(tr.spans) + 1,
+		ParentID: parentID,
+		Name:     name,
+		Attrs:    make(map[string]any),
+		Start:    time.Now(),
+	}
+	tr.spans = append(tr.spans, s)
+
+	return context.WithValue(ctx, spanIDKey{}, s.ID), &span{tr: tr, s: s}
+}
+
+// Spans is the method to get copies of the recorded spans in order of their
+// starts.
+func (tr *Tracer) Spans() []RecordedSpan {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	spans := make([]RecordedSpan, len(tr.spans))
+	for i, s := range tr.spans {
+		spans[i] = *s
+		spans[i].Attrs = make(map[string]any, len(s.Attrs))
+		for k, v := range s.Attrs {
+			spans[i].Attrs[k] = v
+		}
+	}
+	return spans
+}
+
+// SpansNamed is the method to get copies of the recorded spans which have the
+// argument name, in order of their starts.
+func (tr *Tracer) SpansNamed(name string) []RecordedSpan {
+	var spans []RecordedSpan
+	for _, s := range tr.Spans() {
+		if s.Name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Reset is the method to clear the recorded spans.
+func (tr *Tracer) Reset() {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	tr.spans = nil
+}
+
+type span struct {
+	tr *Tracer
+	s  *RecordedSpan
+}
+
+func (sp *span) SetAttr(key string, value any) {
+	sp.tr.mutex.Lock()
+	defer sp.tr.mutex.Unlock()
+	sp.s.Attrs[key] = value
+}
+
+func (sp *span) End(err errs.Err) {
+	sp.tr.mutex.Lock()
+	defer sp.tr.mutex.Unlock()
+	if sp.s.Ended {
+		return
+	}
+	sp.s.End = time.Now()
+	sp.s.Ended = true
+	sp.s.Err = err
+	if err.IsNotOk() {
+		sp.s.Reason = err.ReasonName()
+	}
+}
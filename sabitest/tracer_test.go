@@ -0,0 +1,168 @@
+package sabitest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+)
+
+func TestTracer_Txn_commit(t *testing.T) {
+	tracer := NewTracer()
+
+	reg := sabi.NewRegistry()
+	reg.SetTracer(tracer)
+	reg.Uses("foo", NewDaxSrc("foo", nil))
+	reg.Uses("bar", NewDaxSrc("bar", nil).WithAsyncCommit())
+	assert.True(t, reg.Setup().IsOk())
+	defer reg.Close()
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err := sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		_, err := sabi.GetDaxConn[*DaxConn](dax, "foo")
+		return err
+	}, func(dax sabi.Dax) errs.Err {
+		_, err := sabi.GetDaxConn[*DaxConn](dax, "bar")
+		return err
+	})
+	assert.True(t, err.IsOk())
+
+	spans := tracer.Spans()
+	assert.Equal(t, len(spans), 7)
+
+	assert.Equal(t, spans[0].Name, sabi.SpanTxn)
+	assert.Equal(t, spans[0].ParentID, 0)
+	assert.Equal(t, spans[0].Attrs[sabi.AttrReadOnly], false)
+
+	assert.Equal(t, spans[1].Name, sabi.SpanLogic)
+	assert.Equal(t, spans[1].Attrs[sabi.AttrLogicIndex], 0)
+	assert.Equal(t, spans[2].Name, sabi.SpanCreateDaxConn)
+	assert.Equal(t, spans[2].Attrs[sabi.AttrDaxSrcName], "foo")
+	assert.Equal(t, spans[3].Name, sabi.SpanLogic)
+	assert.Equal(t, spans[3].Attrs[sabi.AttrLogicIndex], 1)
+	assert.Equal(t, spans[4].Name, sabi.SpanCreateDaxConn)
+	assert.Equal(t, spans[4].Attrs[sabi.AttrDaxSrcName], "bar")
+	assert.Equal(t, spans[5].Name, sabi.SpanCommit)
+	assert.Equal(t, spans[5].Attrs[sabi.AttrDaxConnName], "foo")
+	assert.Equal(t, spans[6].Name, sabi.SpanCommit)
+	assert.Equal(t, spans[6].Attrs[sabi.AttrDaxConnName], "bar")
+
+	assert.Equal(t, spans[1].ParentID, spans[0].ID)
+	assert.Equal(t, spans[2].ParentID, spans[1].ID)
+	assert.Equal(t, spans[3].ParentID, spans[0].ID)
+	assert.Equal(t, spans[4].ParentID, spans[3].ID)
+	assert.Equal(t, spans[5].ParentID, spans[0].ID)
+	assert.Equal(t, spans[6].ParentID, spans[0].ID)
+
+	for _, s := range spans {
+		assert.True(t, s.Ended)
+		assert.True(t, s.Err.IsOk())
+		assert.Equal(t, s.Reason, "")
+		assert.True(t, s.Duration() >= 0)
+	}
+}
+
+func TestTracer_Txn_failToCommit(t *testing.T) {
+	tracer := NewTracer()
+
+	reg := sabi.NewRegistry()
+	reg.SetTracer(tracer)
+	reg.Uses("foo", NewDaxSrc("foo", nil))
+	reg.Uses("bar", NewDaxSrc("bar", nil).
+		WithAsyncCommit().WithCommitErr(errs.New(FailToCommit{})))
+	assert.True(t, reg.Setup().IsOk())
+	defer reg.Close()
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err := sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		_, err := sabi.GetDaxConn[*DaxConn](dax, "foo")
+		if err.IsNotOk() {
+			return err
+		}
+		_, err = sabi.GetDaxConn[*DaxConn](dax, "bar")
+		return err
+	})
+	assert.Equal(t, err.ReasonName(), "FailToCommitDaxConn")
+
+	commits := tracer.SpansNamed(sabi.SpanCommit)
+	assert.Equal(t, len(commits), 2)
+	assert.Equal(t, commits[0].Attrs[sabi.AttrDaxConnName], "foo")
+	assert.Equal(t, commits[0].Reason, "")
+	assert.Equal(t, commits[1].Attrs[sabi.AttrDaxConnName], "bar")
+	assert.Equal(t, commits[1].Reason, "FailToCommit")
+	assert.True(t, commits[1].Ended)
+
+	forceBacks := tracer.SpansNamed(sabi.SpanForceBack)
+	assert.Equal(t, len(forceBacks), 1)
+	assert.Equal(t, forceBacks[0].Attrs[sabi.AttrDaxConnName], "foo")
+
+	rollbacks := tracer.SpansNamed(sabi.SpanRollback)
+	assert.Equal(t, len(rollbacks), 1)
+	assert.Equal(t, rollbacks[0].Attrs[sabi.AttrDaxConnName], "bar")
+
+	txns := tracer.SpansNamed(sabi.SpanTxn)
+	assert.Equal(t, len(txns), 1)
+	assert.Equal(t, txns[0].Reason, "FailToCommitDaxConn")
+}
+
+func TestTracer_Txn_failToCreateDaxConn(t *testing.T) {
+	tracer := NewTracer()
+
+	reg := sabi.NewRegistry()
+	reg.SetTracer(tracer)
+	reg.Uses("foo", NewDaxSrc("foo", nil).
+		WithCreateDaxConnErr(errs.New(FailToCreate{})))
+	assert.True(t, reg.Setup().IsOk())
+	defer reg.Close()
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err := sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		_, err := sabi.GetDaxConn[*DaxConn](dax, "foo")
+		return err
+	})
+	assert.Equal(t, err.ReasonName(), "FailToCreateDaxConn")
+
+	creates := tracer.SpansNamed(sabi.SpanCreateDaxConn)
+	assert.Equal(t, len(creates), 1)
+	assert.Equal(t, creates[0].Attrs[sabi.AttrDaxSrcName], "foo")
+	assert.Equal(t, creates[0].Reason, "FailToCreateDaxConn")
+
+	logics := tracer.SpansNamed(sabi.SpanLogic)
+	assert.Equal(t, len(logics), 1)
+	assert.Equal(t, logics[0].Reason, "FailToCreateDaxConn")
+
+	tracer.Reset()
+	assert.Equal(t, len(tracer.Spans()), 0)
+}
+
+func TestTracer_TxnReadOnly(t *testing.T) {
+	tracer := NewTracer()
+
+	reg := sabi.NewRegistry()
+	reg.SetTracer(tracer)
+	assert.True(t, reg.Setup().IsOk())
+
+	base := reg.NewDaxBase()
+	defer base.Close()
+
+	err := sabi.TxnReadOnly(base, func(dax sabi.Dax) errs.Err {
+		return errs.New(FailToDo{})
+	})
+	assert.Equal(t, err.ReasonName(), "FailToDo")
+
+	spans := tracer.Spans()
+	assert.Equal(t, len(spans), 2)
+	assert.Equal(t, spans[0].Name, sabi.SpanTxn)
+	assert.Equal(t, spans[0].Attrs[sabi.AttrReadOnly], true)
+	assert.Equal(t, spans[0].Reason, "FailToDo")
+	assert.Equal(t, spans[1].Name, sabi.SpanLogic)
+	assert.Equal(t, spans[1].Reason, "FailToDo")
+}
@@ -0,0 +1,194 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"strconv"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type /* error reasons */ (
+	// FailToCreateSavepoint is the error reason which indicates that some
+	// connections failed to create a savepoint for a nested transaction.
+	// The field Errors is the map of which keys are registered names of DaxConn
+	// which failed to create a savepoint, and of which values are errs.Err(s)
+	// having their error reasons.
+	FailToCreateSavepoint struct {
+		Errors map[string]errs.Err
+	}
+
+	// FailToReleaseSavepoint is the error reason which indicates that some
+	// connections failed to release a savepoint at the end of a nested
+	// transaction.
+	// The field Errors is the map of which keys are registered names of DaxConn
+	// which failed to release a savepoint, and of which values are errs.Err(s)
+	// having their error reasons.
+	FailToReleaseSavepoint struct {
+		Errors map[string]errs.Err
+	}
+)
+
+// Savepointer is the optional interface which a DaxConn implements to support
+// nested transactions.
+//
+// Savepoint is the method to create a savepoint with the argument name.
+// ReleaseSavepoint is the method to release the savepoint of the argument
+// name, and to keep updates after it as a part of the outer transaction.
+// RollbackToSavepoint is the method to revert updates after the savepoint of
+// the argument name.
+// If these procedures are asynchronous, the argument AsyncGroup(s) are used to
+// process them.
+//
+// When Txn is called in a logic function of another Txn on the same DaxBase,
+// the inner Txn does not commit or close DaxConn(s) but creates a savepoint on
+// each DaxConn implementing this interface.
+// If the inner Txn succeeds, the savepoints are released, otherwise updates
+// are rollbacked only to the savepoints.
+// Updates on DaxConn(s) not implementing this interface are not rollbacked by
+// the inner Txn, but committed or rollbacked with the outer Txn.
+// Since updates are settled by the outer Txn, the inner Txn does not call
+// AfterCommit and AfterRollback of TxnHook(s).
+type Savepointer interface {
+	Savepoint(name string, ag AsyncGroup) errs.Err
+	ReleaseSavepoint(name string, ag AsyncGroup) errs.Err
+	RollbackToSavepoint(name string, ag AsyncGroup)
+}
+
+type nestedTxn struct {
+	savepoint string
+	prev      *nestedTxn
+}
+
+func (base *daxBaseImpl) beginNested(readOnly bool) errs.Err {
+	depth := 1
+	for nt := base.nestedTxn; nt != nil; nt = nt.prev {
+		depth++
+	}
+
+	nt := &nestedTxn{
+		savepoint: "sabi_savepoint_" + strconv.Itoa(depth),
+		prev:      base.nestedTxn,
+	}
+	base.nestedTxn = nt
+
+	// nt is pushed before this check so that end method pops it.
+	if readOnly && !base.readOnly {
+		return errs.New(ReadOnlyTxnIsNestedInReadWriteTxn{})
+	}
+
+	var ag asyncGroupAsync[string]
+	ag.ctx = base.context()
+
+	for ent := base.daxConnMap.Front(); ent != nil; ent = ent.Next() {
+		sp, ok := unwrapDaxConn(ent.Value()).(Savepointer)
+		if !ok {
+			continue
+		}
+		ag.name = ent.Key()
+		err := sp.Savepoint(nt.savepoint, &ag)
+		if err.IsNotOk() {
+			ag.wait()
+			ag.addErr(ent.Key(), err)
+			errMap := ag.makeErrs()
+			return errs.New(FailToCreateSavepoint{Errors: errMap}, causesOf(errMap)...)
+		}
+	}
+
+	ag.wait()
+
+	if ag.hasErr() {
+		errMap := ag.makeErrs()
+		return errs.New(FailToCreateSavepoint{Errors: errMap}, causesOf(errMap)...)
+	}
+
+	return errs.Ok()
+}
+
+func (base *daxBaseImpl) savepointNewDaxConn(name string, conn DaxConn) errs.Err {
+	sp, ok := unwrapDaxConn(conn).(Savepointer)
+	if !ok || base.nestedTxn == nil {
+		return errs.Ok()
+	}
+
+	var savepoints []string
+	for nt := base.nestedTxn; nt != nil; nt = nt.prev {
+		savepoints = append(savepoints, nt.savepoint)
+	}
+
+	var ag asyncGroupAsync[string]
+	ag.ctx = base.context()
+	ag.name = name
+
+	for i := len(savepoints) - 1; i >= 0; i-- {
+		err := sp.Savepoint(savepoints[i], &ag)
+		if err.IsNotOk() {
+			ag.wait()
+			ag.addErr(name, err)
+			errMap := ag.makeErrs()
+			return errs.New(FailToCreateSavepoint{Errors: errMap}, causesOf(errMap)...)
+		}
+	}
+
+	ag.wait()
+
+	if ag.hasErr() {
+		errMap := ag.makeErrs()
+		return errs.New(FailToCreateSavepoint{Errors: errMap}, causesOf(errMap)...)
+	}
+
+	return errs.Ok()
+}
+
+func (base *daxBaseImpl) releaseSavepoint() errs.Err {
+	var ag asyncGroupAsync[string]
+	ag.ctx = base.context()
+
+	for ent := base.daxConnMap.Front(); ent != nil; ent = ent.Next() {
+		sp, ok := unwrapDaxConn(ent.Value()).(Savepointer)
+		if !ok {
+			continue
+		}
+		ag.name = ent.Key()
+		err := sp.ReleaseSavepoint(base.nestedTxn.savepoint, &ag)
+		if err.IsNotOk() {
+			ag.wait()
+			ag.addErr(ent.Key(), err)
+			errMap := ag.makeErrs()
+			return errs.New(FailToReleaseSavepoint{Errors: errMap}, causesOf(errMap)...)
+		}
+	}
+
+	ag.wait()
+
+	if ag.hasErr() {
+		errMap := ag.makeErrs()
+		return errs.New(FailToReleaseSavepoint{Errors: errMap}, causesOf(errMap)...)
+	}
+
+	return errs.Ok()
+}
+
+func (base *daxBaseImpl) rollbackToSavepoint() {
+	var ag asyncGroupAsync[string]
+	ag.ctx = uncanceledCtx{base.context()}
+
+	for ent := base.daxConnMap.Front(); ent != nil; ent = ent.Next() {
+		sp, ok := unwrapDaxConn(ent.Value()).(Savepointer)
+		if ok {
+			sp.RollbackToSavepoint(base.nestedTxn.savepoint, &ag)
+		}
+	}
+
+	ag.wait()
+}
+
+func (base *daxBaseImpl) isNestedTxn() bool {
+	return base.nestedTxn != nil
+}
+
+func (base *daxBaseImpl) endNested() {
+	base.nestedTxn = base.nestedTxn.prev
+}
@@ -0,0 +1,368 @@
+package sabi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+var (
+	WillFailToCreateSavepoint  bool
+	WillFailToReleaseSavepoint bool
+)
+
+type (
+	FailToCreateSpDaxSavepoint  struct{}
+	FailToReleaseSpDaxSavepoint struct{}
+)
+
+type SpDaxSrc struct{}
+
+func (ds SpDaxSrc) Setup(ag AsyncGroup) errs.Err {
+	return errs.Ok()
+}
+func (ds SpDaxSrc) Close() {}
+func (ds SpDaxSrc) CreateDaxConn() (DaxConn, errs.Err) {
+	Logs.PushBack("SpDaxSrc#CreateDaxConn")
+	return &SpDaxConn{}, errs.Ok()
+}
+
+type SpDaxConn struct {
+	committed bool
+}
+
+func (conn *SpDaxConn) Commit(ag AsyncGroup) errs.Err {
+	Logs.PushBack("SpDaxConn#Commit")
+	conn.committed = true
+	return errs.Ok()
+}
+func (conn *SpDaxConn) IsCommitted() bool {
+	return conn.committed
+}
+func (conn *SpDaxConn) Rollback(ag AsyncGroup) {
+	Logs.PushBack("SpDaxConn#Rollback")
+}
+func (conn *SpDaxConn) ForceBack(ag AsyncGroup) {
+	Logs.PushBack("SpDaxConn#ForceBack")
+}
+func (conn *SpDaxConn) Close() {
+	Logs.PushBack("SpDaxConn#Close")
+}
+func (conn *SpDaxConn) Savepoint(name string, ag AsyncGroup) errs.Err {
+	if WillFailToCreateSavepoint {
+		return errs.New(FailToCreateSpDaxSavepoint{})
+	}
+	Logs.PushBack("SpDaxConn#Savepoint " + name)
+	return errs.Ok()
+}
+func (conn *SpDaxConn) ReleaseSavepoint(name string, ag AsyncGroup) errs.Err {
+	ag.Add(func() errs.Err {
+		if WillFailToReleaseSavepoint {
+			return errs.New(FailToReleaseSpDaxSavepoint{})
+		}
+		Logs.PushBack("SpDaxConn#ReleaseSavepoint " + name)
+		return errs.Ok()
+	})
+	return errs.Ok()
+}
+func (conn *SpDaxConn) RollbackToSavepoint(name string, ag AsyncGroup) {
+	Logs.PushBack("SpDaxConn#RollbackToSavepoint " + name)
+}
+
+func resetSavepoint() {
+	WillFailToCreateSavepoint = false
+	WillFailToReleaseSavepoint = false
+}
+
+func TestTxn_nested_ok(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetSavepoint()
+	defer resetSavepoint()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("sp", SpDaxSrc{}).IfOk(base.Uses_("foo", FooDaxSrc{}))
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := GetDaxConn[*SpDaxConn](dax, "sp")
+		assert.True(t, err.IsOk())
+
+		return Txn(base, func(dax Dax) errs.Err {
+			Logs.PushBack("run inner logic")
+			_, err := GetDaxConn[FooDaxConn](dax, "foo")
+			return err
+		})
+	}, func(dax Dax) errs.Err {
+		Logs.PushBack("run outer logic")
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+	assert.False(t, base.(*daxBaseImpl).isLocalDaxSrcsFixed)
+	assert.Nil(t, base.(*daxBaseImpl).nestedTxn)
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "SpDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Savepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "run inner logic")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#ReleaseSavepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "run outer logic")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_nested_innerFails(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetSavepoint()
+	defer resetSavepoint()
+
+	type FailToDoSomething struct{}
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("sp", SpDaxSrc{})
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := GetDaxConn[*SpDaxConn](dax, "sp")
+		assert.True(t, err.IsOk())
+
+		err = Txn(base, func(dax Dax) errs.Err {
+			Logs.PushBack("run inner logic")
+			return errs.New(FailToDoSomething{})
+		})
+		assert.IsType(t, err.Reason(), FailToDoSomething{})
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "SpDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Savepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "run inner logic")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#RollbackToSavepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_nested_daxConnCreatedInInnerTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetSavepoint()
+	defer resetSavepoint()
+
+	type FailToDoSomething struct{}
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("sp", SpDaxSrc{})
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		return Txn(base, func(dax Dax) errs.Err {
+			return Txn(base, func(dax Dax) errs.Err {
+				_, err := GetDaxConn[*SpDaxConn](dax, "sp")
+				assert.True(t, err.IsOk())
+				return errs.New(FailToDoSomething{})
+			})
+		})
+	})
+	assert.IsType(t, err.Reason(), FailToDoSomething{})
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "SpDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Savepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Savepoint sabi_savepoint_2")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#RollbackToSavepoint sabi_savepoint_2")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#RollbackToSavepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_nested_failToCreateSavepoint(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetSavepoint()
+	defer resetSavepoint()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("sp", SpDaxSrc{})
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := GetDaxConn[*SpDaxConn](dax, "sp")
+		assert.True(t, err.IsOk())
+
+		WillFailToCreateSavepoint = true
+
+		return Txn(base, func(dax Dax) errs.Err {
+			Logs.PushBack("run inner logic")
+			return errs.Ok()
+		})
+	})
+	switch r := err.Reason().(type) {
+	case FailToCreateSavepoint:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["sp"].Reason(), FailToCreateSpDaxSavepoint{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "SpDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_nested_failToReleaseSavepoint(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetSavepoint()
+	defer resetSavepoint()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("sp", SpDaxSrc{})
+	assert.True(t, err.IsOk())
+	Logs.Init()
+
+	WillFailToReleaseSavepoint = true
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := GetDaxConn[*SpDaxConn](dax, "sp")
+		assert.True(t, err.IsOk())
+
+		return Txn(base, func(dax Dax) errs.Err {
+			return errs.Ok()
+		})
+	})
+	switch r := err.Reason().(type) {
+	case FailToReleaseSavepoint:
+		assert.Equal(t, len(r.Errors), 1)
+		assert.IsType(t, r.Errors["sp"].Reason(), FailToReleaseSpDaxSavepoint{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "SpDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Savepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#RollbackToSavepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_nested_txnHooks(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetSavepoint()
+	defer resetSavepoint()
+
+	type FailToDoSomething struct{}
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("sp", SpDaxSrc{})
+	assert.True(t, err.IsOk())
+
+	base.AddTxnHook(TxnHook{
+		AfterCommit: func(names []string) {
+			Logs.PushBack("AfterCommit")
+		},
+		AfterRollback: func(names []string, err errs.Err) {
+			Logs.PushBack("AfterRollback " + err.ReasonName())
+		},
+	})
+	Logs.Init()
+
+	err = Txn(base, func(dax Dax) errs.Err {
+		_, err := GetDaxConn[*SpDaxConn](dax, "sp")
+		assert.True(t, err.IsOk())
+
+		err = Txn(base, func(dax Dax) errs.Err {
+			return errs.Ok()
+		})
+		assert.True(t, err.IsOk())
+
+		err = Txn(base, func(dax Dax) errs.Err {
+			return errs.New(FailToDoSomething{})
+		})
+		assert.IsType(t, err.Reason(), FailToDoSomething{})
+
+		return errs.New(FailToDoSomething{})
+	})
+	assert.IsType(t, err.Reason(), FailToDoSomething{})
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "SpDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Savepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#ReleaseSavepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Savepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#RollbackToSavepoint sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "AfterRollback FailToDoSomething")
+	log = log.Next()
+	assert.Equal(t, log.Value, "SpDaxConn#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
@@ -0,0 +1,203 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type /* error reasons */ (
+	// AppIsShuttingDown is the error reason which indicates that a transaction
+	// cannot begin because Shutdown function is called.
+	AppIsShuttingDown struct{}
+
+	// TxnsAreNotDrained is the error reason which indicates that in-flight
+	// transactions did not finish before the context.Context of Shutdown
+	// function was done.
+	// The field Count is the number of transactions still running.
+	TxnsAreNotDrained struct {
+		Count int
+	}
+
+	// DaxSrcCloseIsTimedOut is the error reason which indicates that a DaxSrc
+	// did not finish closing before the context.Context of Shutdown function was
+	// done.
+	DaxSrcCloseIsTimedOut struct{}
+
+	// DaxSrcPanicsOnClose is the error reason which indicates that a DaxSrc
+	// panicked while closing.
+	// The field Value is the value passed to panic.
+	DaxSrcPanicsOnClose struct {
+		Value any
+	}
+)
+
+type txnGate struct {
+	mutex    sync.Mutex
+	count    int
+	shutdown bool
+	drained  chan struct{}
+	closed   bool
+}
+
+func (gate *txnGate) enter() errs.Err {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	if gate.shutdown {
+		return errs.New(AppIsShuttingDown{})
+	}
+	gate.count++
+	return errs.Ok()
+}
+
+func (gate *txnGate) exit() {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	gate.count--
+	if gate.shutdown && gate.count == 0 {
+		close(gate.drained)
+	}
+}
+
+// close forbids new transactions to begin, and returns the channel which is
+// closed when in-flight transactions finish.
+// The same channel is returned when this method is called again.
+func (gate *txnGate) close() <-chan struct{} {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	if !gate.shutdown {
+		gate.shutdown = true
+		gate.drained = make(chan struct{})
+		if gate.count == 0 {
+			close(gate.drained)
+		}
+	}
+	return gate.drained
+}
+
+// markClosed returns true only when this method is called for the first time,
+// so that DaxSrc(s) are closed only once.
+func (gate *txnGate) markClosed() bool {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	if gate.closed {
+		return false
+	}
+	gate.closed = true
+	return true
+}
+
+func (gate *txnGate) running() int {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	return gate.count
+}
+
+// Shutdown is the function that shuts down global DaxSrc(s) gracefully.
+//
+// First, this function forbids new transactions to begin, and transaction
+// functions called after this return an errs.Err of the reason:
+// AppIsShuttingDown.
+// Next, this function waits for in-flight transactions to finish.
+// If the argument context.Context is done before that, this function returns
+// an errs.Err of the reason: TxnsAreNotDrained, without closing any DaxSrc.
+// In this case, this function can be called again to wait for the in-flight
+// transactions and to close DaxSrc(s).
+// Then, this function closes global DaxSrc(s) in reverse order of their
+// setups.
+// If some of DaxSrc(s) fail to close, panic while closing, or do not finish
+// closing before the context.Context is done, this function returns an
+// errs.Err of the reason: FailToCloseGlobalDaxSrcs.
+//
+// This function does nothing after it has started closing DaxSrc(s) once.
+func Shutdown(ctx context.Context) errs.Err {
+	return defaultRegistry.Shutdown(ctx)
+}
+
+// Shutdown is the method that shuts down global DaxSrc(s) of this Registry
+// gracefully.
+// See Shutdown function for details.
+func (reg *Registry) Shutdown(ctx context.Context) errs.Err {
+	drained := reg.txnGate.close()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		return errs.New(TxnsAreNotDrained{Count: reg.txnGate.running()}, ctx.Err())
+	}
+
+	if !reg.txnGate.markClosed() {
+		return errs.Ok()
+	}
+
+	type closeResult struct {
+		name string
+		err  errs.Err
+	}
+
+	ch := make(chan closeResult)
+	pending := make(map[string]bool)
+	for ent := reg.daxSrcEntryList.head; ent != nil; ent = ent.next {
+		pending[ent.name] = true
+	}
+
+	go func() {
+		for ent := reg.daxSrcEntryList.last; ent != nil; ent = ent.prev {
+			ch <- closeResult{name: ent.name, err: closeDaxSrc(ent.daxSrc())}
+		}
+		close(ch)
+	}()
+
+	var ag asyncGroupAsync[string]
+
+loop:
+	for {
+		select {
+		case r, ok := <-ch:
+			if !ok {
+				break loop
+			}
+			delete(pending, r.name)
+			if r.err.IsNotOk() {
+				ag.addErr(r.name, r.err)
+			}
+		case <-ctx.Done():
+			for name := range pending {
+				ag.addErr(name, errs.New(DaxSrcCloseIsTimedOut{}, ctx.Err()))
+			}
+			go func() {
+				for range ch {
+				}
+			}()
+			break loop
+		}
+	}
+
+	if ag.hasErr() {
+		errMap := ag.makeErrs()
+		return errs.New(FailToCloseGlobalDaxSrcs{Errors: errMap}, causesOf(errMap)...)
+	}
+
+	return errs.Ok()
+}
+
+func closeDaxSrc(ds DaxSrc) (err errs.Err) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = errs.New(DaxSrcPanicsOnClose{Value: r})
+		}
+	}()
+
+	return closeWithErr(ds)
+}
@@ -0,0 +1,178 @@
+package sabi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type SlowCloseDaxSrc struct {
+	FooDaxSrc
+	wait  time.Duration
+	panic bool
+}
+
+func (ds SlowCloseDaxSrc) Close() {
+	if ds.panic {
+		panic("fail to close")
+	}
+	time.Sleep(ds.wait)
+}
+
+func TestShutdown_ok(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("foo", FooDaxSrc{})
+	Uses("bar", &BarDaxSrc{})
+	assert.True(t, Setup().IsOk())
+	Logs.Init()
+
+	err := Shutdown(context.Background())
+	assert.True(t, err.IsOk())
+
+	base := NewDaxBase()
+	err = Txn(base, func(dax Dax) errs.Err {
+		Logs.PushBack("run logic")
+		return errs.Ok()
+	})
+	assert.IsType(t, err.Reason(), AppIsShuttingDown{})
+
+	err = Shutdown(context.Background())
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "BarDaxSrc#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestShutdown_waitForInFlightTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("foo", FooDaxSrc{})
+	assert.True(t, Setup().IsOk())
+	Logs.Init()
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan errs.Err)
+
+	go func() {
+		base := NewDaxBase()
+		done <- Txn(base, func(dax Dax) errs.Err {
+			_, err := GetDaxConn[FooDaxConn](dax, "foo")
+			close(started)
+			<-finish
+			return err
+		})
+	}()
+
+	<-started
+	time.AfterFunc(20*time.Millisecond, func() { close(finish) })
+
+	err := Shutdown(context.Background())
+	assert.True(t, err.IsOk())
+	assert.True(t, (<-done).IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "FooDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestShutdown_txnsAreNotDrained(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("foo", FooDaxSrc{})
+	assert.True(t, Setup().IsOk())
+	Logs.Init()
+
+	base := NewDaxBase().(*daxBaseImpl)
+	base.beginCtx(context.Background(), false)
+	defer base.end()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := Shutdown(ctx)
+	switch r := err.Reason().(type) {
+	case TxnsAreNotDrained:
+		assert.Equal(t, r.Count, 1)
+		assert.Equal(t, err.Cause(), context.DeadlineExceeded)
+	default:
+		assert.Fail(t, err.Error())
+	}
+	assert.Nil(t, Logs.Front())
+}
+
+func TestShutdown_retryAfterTxnsAreNotDrained(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("foo", FooDaxSrc{})
+	assert.True(t, Setup().IsOk())
+	Logs.Init()
+
+	base := NewDaxBase().(*daxBaseImpl)
+	assert.True(t, base.beginCtx(context.Background(), false).IsOk())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Shutdown(ctx)
+	assert.IsType(t, err.Reason(), TxnsAreNotDrained{})
+	assert.Nil(t, Logs.Front())
+
+	base.end()
+
+	err = Shutdown(context.Background())
+	assert.True(t, err.IsOk())
+
+	err = Shutdown(context.Background())
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestShutdown_failToClose(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Uses("foo", SlowCloseDaxSrc{})
+	Uses("slow", SlowCloseDaxSrc{wait: 100 * time.Millisecond})
+	Uses("panic", SlowCloseDaxSrc{panic: true})
+	assert.True(t, Setup().IsOk())
+	Logs.Init()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := Shutdown(ctx)
+	switch r := err.Reason().(type) {
+	case FailToCloseGlobalDaxSrcs:
+		assert.Equal(t, len(r.Errors), 3)
+		assert.Equal(t, r.Errors["panic"].Reason(), DaxSrcPanicsOnClose{Value: "fail to close"})
+		assert.IsType(t, r.Errors["slow"].Reason(), DaxSrcCloseIsTimedOut{})
+		assert.IsType(t, r.Errors["foo"].Reason(), DaxSrcCloseIsTimedOut{})
+	default:
+		assert.Fail(t, err.Error())
+	}
+}
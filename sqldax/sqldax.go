@@ -0,0 +1,295 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+// sqldax is the package that provides a DaxSrc and a DaxConn for data stores
+// accessed with database/sql.
+//
+// DaxSrc opens a *sql.DB at its Setup, and creates a DaxConn for each
+// transaction.
+// DaxConn begins a *sql.Tx lazily when its Tx method is called first in the
+// transaction, and commits or rollbacks it at the end of the transaction.
+//
+//	sabi.Uses("database", sqldax.NewDaxSrc("postgres", dsn).
+//	    WithIsolationLevel(sql.LevelSerializable))
+//
+//	func (dax DatabaseDax) GetUserName(id int) (string, errs.Err) {
+//	    conn, err := sabi.GetDaxConn[*sqldax.DaxConn](dax, "database")
+//	    if err.IsNotOk() {
+//	        return "", err
+//	    }
+//	    tx, err := conn.Tx()
+//	    if err.IsNotOk() {
+//	        return "", err
+//	    }
+//	    ...
+//	}
+package sqldax
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+)
+
+type /* error reasons */ (
+	// FailToOpenDB is the error reason which indicates that it is failed to
+	// open a *sql.DB.
+	// The fields DriverName and DataSourceName are the arguments of sql.Open.
+	FailToOpenDB struct {
+		DriverName, DataSourceName string
+	}
+
+	// FailToPingDB is the error reason which indicates that it is failed to
+	// verify a connection to a database at the set up.
+	// The field DriverName is the name of the database driver.
+	FailToPingDB struct {
+		DriverName string
+	}
+
+	// FailToCloseDB is the error reason which indicates that it is failed to
+	// close a *sql.DB.
+	// The field DriverName is the name of the database driver.
+	FailToCloseDB struct {
+		DriverName string
+	}
+
+	// DBIsNotSetup is the error reason which indicates that a DaxSrc is not
+	// set up or already closed, when creating a DaxConn.
+	DBIsNotSetup struct{}
+
+	// FailToBeginTx is the error reason which indicates that it is failed to
+	// begin a *sql.Tx.
+	FailToBeginTx struct{}
+
+	// FailToCommitTx is the error reason which indicates that it is failed to
+	// commit a *sql.Tx.
+	FailToCommitTx struct{}
+
+	// FailToExecSavepoint is the error reason which indicates that it is failed
+	// to execute a SQL statement for a savepoint.
+	// The field Statement is the executed SQL statement.
+	FailToExecSavepoint struct {
+		Statement string
+	}
+)
+
+// DaxSrc is the struct type that implements sabi.DaxSrc for database/sql.
+type DaxSrc struct {
+	driverName     string
+	dataSourceName string
+	txOpts         sql.TxOptions
+	db             *sql.DB
+}
+
+// NewDaxSrc is the function that creates a new DaxSrc instance with a driver
+// name and a data source name, which are passed to sql.Open at its Setup.
+func NewDaxSrc(driverName, dataSourceName string) *DaxSrc {
+	return &DaxSrc{driverName: driverName, dataSourceName: dataSourceName}
+}
+
+// WithIsolationLevel is the method to set the isolation level of *sql.Tx
+// which DaxConn(s) created by this DaxSrc begin.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithIsolationLevel(level sql.IsolationLevel) *DaxSrc {
+	ds.txOpts.Isolation = level
+	return ds
+}
+
+// WithReadOnly is the method to make *sql.Tx which DaxConn(s) created by this
+// DaxSrc begin read-only.
+// This method returns this DaxSrc itself.
+func (ds *DaxSrc) WithReadOnly() *DaxSrc {
+	ds.txOpts.ReadOnly = true
+	return ds
+}
+
+// Setup is the method to open a *sql.DB and to verify a connection to the
+// database asynchronously.
+func (ds *DaxSrc) Setup(ag sabi.AsyncGroup) errs.Err {
+	db, e := sql.Open(ds.driverName, ds.dataSourceName)
+	if e != nil {
+		return errs.New(FailToOpenDB{
+			DriverName:     ds.driverName,
+			DataSourceName: ds.dataSourceName,
+		}, e)
+	}
+	ds.db = db
+
+	ctx := sabi.ContextOf(ag)
+	ag.Add(func() errs.Err {
+		e := db.PingContext(ctx)
+		if e != nil {
+			return errs.New(FailToPingDB{DriverName: ds.driverName}, e)
+		}
+		return errs.Ok()
+	})
+
+	return errs.Ok()
+}
+
+// Close is the method to close the *sql.DB.
+func (ds *DaxSrc) Close() {
+	ds.CloseWithErr()
+}
+
+// CloseWithErr is the method to close the *sql.DB and to return an errs.Err
+// if it is failed.
+func (ds *DaxSrc) CloseWithErr() errs.Err {
+	if ds.db == nil {
+		return errs.Ok()
+	}
+	e := ds.db.Close()
+	ds.db = nil
+	if e != nil {
+		return errs.New(FailToCloseDB{DriverName: ds.driverName}, e)
+	}
+	return errs.Ok()
+}
+
+// HealthCheck is the method to verify a connection to the database is still
+// alive.
+func (ds *DaxSrc) HealthCheck(ctx context.Context) errs.Err {
+	if ds.db == nil {
+		return errs.New(DBIsNotSetup{})
+	}
+	e := ds.db.PingContext(ctx)
+	if e != nil {
+		return errs.New(FailToPingDB{DriverName: ds.driverName}, e)
+	}
+	return errs.Ok()
+}
+
+// CreateDaxConn is the method to create a DaxConn instance.
+func (ds *DaxSrc) CreateDaxConn() (sabi.DaxConn, errs.Err) {
+	return ds.CreateDaxConnCtx(context.Background())
+}
+
+// CreateDaxConnCtx is the method to create a DaxConn instance which begins a
+// *sql.Tx with the argument context.Context.
+func (ds *DaxSrc) CreateDaxConnCtx(ctx context.Context) (sabi.DaxConn, errs.Err) {
+	if ds.db == nil {
+		return nil, errs.New(DBIsNotSetup{})
+	}
+	return &DaxConn{db: ds.db, txOpts: ds.txOpts, ctx: ctx}, errs.Ok()
+}
+
+// DaxConn is the struct type that implements sabi.DaxConn for database/sql.
+type DaxConn struct {
+	db        *sql.DB
+	txOpts    sql.TxOptions
+	ctx       context.Context
+	tx        *sql.Tx
+	committed bool
+}
+
+// DB is the method to get the *sql.DB which this DaxConn uses.
+func (conn *DaxConn) DB() *sql.DB {
+	return conn.db
+}
+
+// Tx is the method to get the *sql.Tx of the current transaction.
+// A *sql.Tx is begun when this method is called first in the transaction.
+func (conn *DaxConn) Tx() (*sql.Tx, errs.Err) {
+	if conn.tx != nil {
+		return conn.tx, errs.Ok()
+	}
+
+	tx, e := conn.db.BeginTx(conn.ctx, &conn.txOpts)
+	if e != nil {
+		return nil, errs.New(FailToBeginTx{}, e)
+	}
+	conn.tx = tx
+
+	return tx, errs.Ok()
+}
+
+// Commit is the method to commit the *sql.Tx if it is begun.
+func (conn *DaxConn) Commit(ag sabi.AsyncGroup) errs.Err {
+	if conn.tx != nil {
+		e := conn.tx.Commit()
+		if e != nil {
+			return errs.New(FailToCommitTx{}, e)
+		}
+	}
+	conn.committed = true
+	return errs.Ok()
+}
+
+// IsCommitted is the method to check whether the *sql.Tx is already
+// committed.
+func (conn *DaxConn) IsCommitted() bool {
+	return conn.committed
+}
+
+// Rollback is the method to rollback the *sql.Tx if it is begun.
+func (conn *DaxConn) Rollback(ag sabi.AsyncGroup) {
+	if conn.tx != nil {
+		conn.tx.Rollback()
+	}
+}
+
+// ForceBack is the method to revert updates forcely.
+// Since database/sql cannot revert a committed *sql.Tx, this method only
+// rollbacks the *sql.Tx if it is not committed yet.
+func (conn *DaxConn) ForceBack(ag sabi.AsyncGroup) {
+	if !conn.committed {
+		conn.Rollback(ag)
+	}
+}
+
+// Close is the method to end the *sql.Tx.
+// If the *sql.Tx is neither committed nor rollbacked, it is rollbacked.
+func (conn *DaxConn) Close() {
+	if conn.tx != nil {
+		conn.tx.Rollback()
+		conn.tx = nil
+	}
+}
+
+// SetReadOnly is the method to make the *sql.Tx of this DaxConn read-only.
+// This method is called when this DaxConn is created in a read-only
+// transaction.
+func (conn *DaxConn) SetReadOnly() {
+	conn.txOpts.ReadOnly = true
+}
+
+// IsWriteAttempted is the method to check whether a write was attempted in a
+// read-only transaction.
+// Since a database reports a write to a read-only *sql.Tx as an error of the
+// statement, this method always returns false.
+func (conn *DaxConn) IsWriteAttempted() bool {
+	return false
+}
+
+// Savepoint is the method to create a savepoint in the *sql.Tx for a nested
+// transaction.
+// If the *sql.Tx is not begun yet, this method begins it.
+func (conn *DaxConn) Savepoint(name string, ag sabi.AsyncGroup) errs.Err {
+	return conn.execSavepoint("SAVEPOINT " + name)
+}
+
+// ReleaseSavepoint is the method to release a savepoint in the *sql.Tx.
+func (conn *DaxConn) ReleaseSavepoint(name string, ag sabi.AsyncGroup) errs.Err {
+	return conn.execSavepoint("RELEASE SAVEPOINT " + name)
+}
+
+// RollbackToSavepoint is the method to rollback the *sql.Tx to a savepoint.
+func (conn *DaxConn) RollbackToSavepoint(name string, ag sabi.AsyncGroup) {
+	conn.execSavepoint("ROLLBACK TO SAVEPOINT " + name)
+}
+
+func (conn *DaxConn) execSavepoint(stmt string) errs.Err {
+	tx, err := conn.Tx()
+	if err.IsNotOk() {
+		return err
+	}
+
+	_, e := tx.ExecContext(conn.ctx, stmt)
+	if e != nil {
+		return errs.New(FailToExecSavepoint{Statement: stmt}, e)
+	}
+	return errs.Ok()
+}
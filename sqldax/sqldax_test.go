@@ -0,0 +1,542 @@
+package sqldax
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi"
+	"github.com/sttk/sabi/errs"
+)
+
+var (
+	Logs                list.List
+	WillFailToBeginTx   bool
+	WillFailToCommitTx  bool
+	WillFailToConnectDB bool
+	WillFailToExec      bool
+	WillFailToCloseConn bool
+)
+
+func Reset() {
+	WillFailToExec = false
+	WillFailToBeginTx = false
+	WillFailToCommitTx = false
+	WillFailToConnectDB = false
+	WillFailToCloseConn = false
+	Logs.Init()
+}
+
+///
+
+type fakeDriver struct{}
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) {
+	if WillFailToConnectDB {
+		return nil, errors.New("fail to connect")
+	}
+	Logs.PushBack("Open " + name)
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if WillFailToExec {
+		return nil, errors.New("fail to exec")
+	}
+	Logs.PushBack("Exec " + query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c fakeConn) Close() error {
+	if WillFailToCloseConn {
+		return errors.New("fail to close")
+	}
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if WillFailToBeginTx {
+		return nil, errors.New("fail to begin")
+	}
+	Logs.PushBack(fmt.Sprintf("BeginTx isolation=%v readonly=%v",
+		sql.IsolationLevel(opts.Isolation), opts.ReadOnly))
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (tx fakeTx) Commit() error {
+	if WillFailToCommitTx {
+		return errors.New("fail to commit")
+	}
+	Logs.PushBack("Commit")
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	Logs.PushBack("Rollback")
+	return nil
+}
+
+func init() {
+	sql.Register("sqldax_fake", fakeDriver{})
+}
+
+type syncAsyncGroup struct {
+	err errs.Err
+}
+
+func (ag *syncAsyncGroup) Add(fn func() errs.Err) {
+	ag.err = fn()
+}
+
+///
+
+func TestDaxSrc_Setup_ok(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+
+	var ag syncAsyncGroup
+	err := ds.Setup(&ag)
+	assert.True(t, err.IsOk())
+	assert.True(t, ag.err.IsOk())
+	assert.NotNil(t, ds.db)
+
+	ds.Close()
+	assert.Nil(t, ds.db)
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open db0")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestDaxSrc_Setup_failToOpenDB(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_unknown", "db0")
+
+	var ag syncAsyncGroup
+	err := ds.Setup(&ag)
+	switch r := err.Reason().(type) {
+	case FailToOpenDB:
+		assert.Equal(t, r.DriverName, "sqldax_unknown")
+		assert.Equal(t, r.DataSourceName, "db0")
+	default:
+		assert.Fail(t, err.Error())
+	}
+}
+
+func TestDaxSrc_Setup_failToPingDB(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	WillFailToConnectDB = true
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+	defer ds.Close()
+
+	var ag syncAsyncGroup
+	err := ds.Setup(&ag)
+	assert.True(t, err.IsOk())
+	switch r := ag.err.Reason().(type) {
+	case FailToPingDB:
+		assert.Equal(t, r.DriverName, "sqldax_fake")
+	default:
+		assert.Fail(t, ag.err.Error())
+	}
+}
+
+func TestDaxSrc_CreateDaxConn_notSetup(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+
+	_, err := ds.CreateDaxConn()
+	assert.IsType(t, err.Reason(), DBIsNotSetup{})
+}
+
+func TestDaxConn_Tx_beginLazilyAndCommit(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+	var ag syncAsyncGroup
+	assert.True(t, ds.Setup(&ag).IsOk())
+	defer ds.Close()
+
+	c, err := ds.CreateDaxConn()
+	assert.True(t, err.IsOk())
+	conn := c.(*DaxConn)
+	assert.Equal(t, conn.DB(), ds.db)
+	assert.Nil(t, conn.tx)
+
+	tx1, err := conn.Tx()
+	assert.True(t, err.IsOk())
+	tx2, err := conn.Tx()
+	assert.True(t, err.IsOk())
+	assert.Equal(t, tx1, tx2)
+
+	assert.False(t, conn.IsCommitted())
+	err = conn.Commit(&ag)
+	assert.True(t, err.IsOk())
+	assert.True(t, conn.IsCommitted())
+	conn.Close()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open db0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BeginTx isolation=Default readonly=false")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Commit")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestDaxConn_Commit_withoutTx(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+	var ag syncAsyncGroup
+	assert.True(t, ds.Setup(&ag).IsOk())
+	defer ds.Close()
+
+	conn, err := ds.CreateDaxConn()
+	assert.True(t, err.IsOk())
+
+	err = conn.Commit(&ag)
+	assert.True(t, err.IsOk())
+	assert.True(t, conn.IsCommitted())
+	conn.Close()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open db0")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestDaxConn_Tx_failToBeginTx(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+	var ag syncAsyncGroup
+	assert.True(t, ds.Setup(&ag).IsOk())
+	defer ds.Close()
+
+	c, err := ds.CreateDaxConn()
+	assert.True(t, err.IsOk())
+
+	WillFailToBeginTx = true
+
+	_, err = c.(*DaxConn).Tx()
+	assert.IsType(t, err.Reason(), FailToBeginTx{})
+	assert.NotNil(t, err.Cause())
+}
+
+func TestDaxConn_Commit_failToCommitTx(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+	var ag syncAsyncGroup
+	assert.True(t, ds.Setup(&ag).IsOk())
+	defer ds.Close()
+
+	c, err := ds.CreateDaxConn()
+	assert.True(t, err.IsOk())
+	conn := c.(*DaxConn)
+
+	_, err = conn.Tx()
+	assert.True(t, err.IsOk())
+
+	WillFailToCommitTx = true
+
+	err = conn.Commit(&ag)
+	assert.IsType(t, err.Reason(), FailToCommitTx{})
+	assert.False(t, conn.IsCommitted())
+}
+
+func TestDaxConn_Rollback(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0").
+		WithIsolationLevel(sql.LevelSerializable).
+		WithReadOnly()
+	var ag syncAsyncGroup
+	assert.True(t, ds.Setup(&ag).IsOk())
+	defer ds.Close()
+
+	c, err := ds.CreateDaxConn()
+	assert.True(t, err.IsOk())
+	conn := c.(*DaxConn)
+
+	_, err = conn.Tx()
+	assert.True(t, err.IsOk())
+
+	conn.Rollback(&ag)
+	conn.Close()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open db0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BeginTx isolation=Serializable readonly=true")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Rollback")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestDaxConn_Close_rollbackIfNotEnded(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+	var ag syncAsyncGroup
+	assert.True(t, ds.Setup(&ag).IsOk())
+	defer ds.Close()
+
+	c, err := ds.CreateDaxConn()
+	assert.True(t, err.IsOk())
+	conn := c.(*DaxConn)
+
+	_, err = conn.Tx()
+	assert.True(t, err.IsOk())
+
+	conn.Close()
+	assert.Nil(t, conn.tx)
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open db0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BeginTx isolation=Default readonly=false")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Rollback")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestDaxConn_withTxn(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := sabi.NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("database", NewDaxSrc("sqldax_fake", "db0"))
+	assert.True(t, err.IsOk())
+
+	type FailToDoSomething struct{}
+
+	err = sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn](dax, "database")
+		if err.IsNotOk() {
+			return err
+		}
+		_, err = conn.Tx()
+		return err
+	})
+	assert.True(t, err.IsOk())
+
+	err = sabi.TxnCtx(context.Background(), base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn](dax, "database")
+		if err.IsNotOk() {
+			return err
+		}
+		_, err = conn.Tx()
+		if err.IsNotOk() {
+			return err
+		}
+		return errs.New(FailToDoSomething{})
+	})
+	assert.IsType(t, err.Reason(), FailToDoSomething{})
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open db0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BeginTx isolation=Default readonly=false")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BeginTx isolation=Default readonly=false")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Rollback")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestDaxConn_savepoints(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := sabi.NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("database", NewDaxSrc("sqldax_fake", "db0"))
+	assert.True(t, err.IsOk())
+
+	type FailToDoSomething struct{}
+
+	err = sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn](dax, "database")
+		if err.IsNotOk() {
+			return err
+		}
+		_, err = conn.Tx()
+		if err.IsNotOk() {
+			return err
+		}
+		err = sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+			return errs.Ok()
+		})
+		if err.IsNotOk() {
+			return err
+		}
+		err = sabi.Txn(base, func(dax sabi.Dax) errs.Err {
+			return errs.New(FailToDoSomething{})
+		})
+		assert.IsType(t, err.Reason(), FailToDoSomething{})
+		return errs.Ok()
+	})
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open db0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BeginTx isolation=Default readonly=false")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Exec SAVEPOINT sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Exec RELEASE SAVEPOINT sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Exec SAVEPOINT sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Exec ROLLBACK TO SAVEPOINT sabi_savepoint_1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Commit")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestDaxConn_Savepoint_failToExec(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+	var ag syncAsyncGroup
+	assert.True(t, ds.Setup(&ag).IsOk())
+	defer ds.Close()
+
+	c, err := ds.CreateDaxConn()
+	assert.True(t, err.IsOk())
+	defer c.Close()
+
+	WillFailToExec = true
+
+	err = c.(*DaxConn).Savepoint("sp1", &ag)
+	switch r := err.Reason().(type) {
+	case FailToExecSavepoint:
+		assert.Equal(t, r.Statement, "SAVEPOINT sp1")
+	default:
+		assert.Fail(t, err.Error())
+	}
+}
+
+func TestDaxConn_withTxnReadOnly(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := sabi.NewDaxBase()
+	defer base.Close()
+
+	err := base.Uses("database", NewDaxSrc("sqldax_fake", "db0"))
+	assert.True(t, err.IsOk())
+
+	err = sabi.TxnReadOnly(base, func(dax sabi.Dax) errs.Err {
+		conn, err := sabi.GetDaxConn[*DaxConn](dax, "database")
+		if err.IsNotOk() {
+			return err
+		}
+		_, err = conn.Tx()
+		return err
+	})
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Open db0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BeginTx isolation=Default readonly=true")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Rollback")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestDaxSrc_HealthCheck(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+
+	err := ds.HealthCheck(context.Background())
+	assert.IsType(t, err.Reason(), DBIsNotSetup{})
+
+	var ag syncAsyncGroup
+	assert.True(t, ds.Setup(&ag).IsOk())
+	defer ds.Close()
+
+	err = ds.HealthCheck(context.Background())
+	assert.True(t, err.IsOk())
+
+	ds.db.SetMaxIdleConns(0)
+	WillFailToConnectDB = true
+
+	err = ds.HealthCheck(context.Background())
+	assert.IsType(t, err.Reason(), FailToPingDB{})
+}
+
+func TestDaxSrc_CloseWithErr(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ds := NewDaxSrc("sqldax_fake", "db0")
+
+	err := ds.CloseWithErr()
+	assert.True(t, err.IsOk())
+
+	var ag syncAsyncGroup
+	assert.True(t, ds.Setup(&ag).IsOk())
+
+	WillFailToCloseConn = true
+
+	err = ds.CloseWithErr()
+	switch r := err.Reason().(type) {
+	case FailToCloseDB:
+		assert.Equal(t, r.DriverName, "sqldax_fake")
+	default:
+		assert.Fail(t, err.Error())
+	}
+	assert.Nil(t, ds.db)
+}
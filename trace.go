@@ -0,0 +1,205 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sttk/sabi/errs"
+)
+
+// Names of spans which transactions emit.
+const (
+	// SpanTxn is the name of a span for a whole transaction.
+	SpanTxn = "sabi.Txn"
+
+	// SpanLogic is the name of a span for each logic function of a transaction.
+	SpanLogic = "sabi.Logic"
+
+	// SpanCreateDaxConn is the name of a span for creating a DaxConn from a
+	// DaxSrc.
+	// This span is a child of the SpanLogic span of the logic function which
+	// gets the DaxConn.
+	SpanCreateDaxConn = "sabi.CreateDaxConn"
+
+	// SpanPrepare is the name of a span for preparing a Preparable DaxConn.
+	SpanPrepare = "sabi.Prepare"
+
+	// SpanCommit is the name of a span for committing a DaxConn.
+	SpanCommit = "sabi.Commit"
+
+	// SpanRollback is the name of a span for rollbacking a DaxConn.
+	SpanRollback = "sabi.Rollback"
+
+	// SpanForceBack is the name of a span for forcing back a committed
+	// DaxConn.
+	SpanForceBack = "sabi.ForceBack"
+)
+
+// Keys of attributes which spans are annotated with.
+const (
+	// AttrReadOnly is the attribute key of a SpanTxn span, of which the value
+	// is true if the transaction is read-only.
+	AttrReadOnly = "sabi.read_only"
+
+	// AttrLogicIndex is the attribute key of a SpanLogic span, of which the
+	// value is the index of the logic function.
+	AttrLogicIndex = "sabi.logic.index"
+
+	// AttrDaxSrcName is the attribute key of a SpanCreateDaxConn span, of which
+	// the value is the registered name of the DaxSrc.
+	AttrDaxSrcName = "sabi.daxsrc.name"
+
+	// AttrDaxConnName is the attribute key of SpanPrepare, SpanCommit,
+	// SpanRollback, and SpanForceBack spans, of which the value is the name of
+	// the DaxConn.
+	AttrDaxConnName = "sabi.daxconn.name"
+)
+
+// Span is the interface for a unit of work traced in a transaction.
+// SetAttr is the method to annotate this span with a key and a value.
+// End is the method to finish this span with the errs.Err of the work.
+// If the work failed, the errs.Err is not ok and the reason name of it can be
+// got with errs.Err#ReasonName.
+type Span interface {
+	SetAttr(key string, value any)
+	End(err errs.Err)
+}
+
+// Tracer is the interface to start spans.
+// Start is the method to start a span which has the argument name, and to
+// return a context.Context carrying that span, which is passed to Start
+// method for child spans.
+//
+// Implementations of this interface can adapt sabi to tracing libraries like
+// OpenTelemetry.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type noopTracer struct{}
+
+func (t noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (s noopSpan) SetAttr(key string, value any) {}
+func (s noopSpan) End(err errs.Err)              {}
+
+// NoopTracer is the function to get a Tracer which does nothing.
+// This Tracer is used by default.
+func NoopTracer() Tracer {
+	return noopTracer{}
+}
+
+// SetTracer is the function to set a Tracer which spans of transactions of
+// all DaxBase(s) are started with.
+// If the argument Tracer is nil, the Tracer which does nothing is set.
+//
+// Like Uses function, this function ignores setting a new Tracer after Setup
+// or beginning of Txn.
+func SetTracer(tracer Tracer) {
+	defaultRegistry.SetTracer(tracer)
+}
+
+// SetTracer is the method to set a Tracer which spans of transactions of all
+// DaxBase(s) created by this Registry are started with.
+// See SetTracer function for details.
+func (reg *Registry) SetTracer(tracer Tracer) {
+	if reg.isDaxSrcsFixed {
+		return
+	}
+
+	if tracer == nil {
+		tracer = noopTracer{}
+	}
+	reg.tracer = tracer
+}
+
+func (base *daxBaseImpl) getTracer() Tracer {
+	return base.registry.tracer
+}
+
+// observeDaxConn runs the argument function which operates a DaxConn, with a
+// span of the argument name.
+// If the operation is a commit, its duration is also recorded to the
+// MetricsSink.
+func (base *daxBaseImpl) observeDaxConn(
+	ag AsyncGroup, spanName, connName string, fn func(ag AsyncGroup) errs.Err,
+) errs.Err {
+	tracer := base.registry.tracer
+	sink := base.registry.metrics
+	_, noTracer := tracer.(noopTracer)
+	_, noSink := sink.(noopMetricsSink)
+	if noTracer && noSink {
+		return fn(ag)
+	}
+
+	ctx, span := tracer.Start(ContextOf(ag), spanName)
+	span.SetAttr(AttrDaxConnName, connName)
+	start := time.Now()
+
+	oag := &asyncGroupObserved{parent: ag, ctx: ctx, pending: 1}
+	oag.end = func(err errs.Err) {
+		span.End(err)
+		if spanName == SpanCommit {
+			labels := map[string]string{LabelDaxConn: connName}
+			sink.ObserveHistogram(MetricCommitDuration, labels,
+				time.Since(start).Seconds())
+		}
+	}
+
+	err := fn(oag)
+	oag.done(err)
+	return err
+}
+
+// asyncGroupObserved is an AsyncGroup which calls the end function after the
+// synchronous part and all asynchronous functions of a DaxConn operation
+// finish.
+// Asynchronous functions are run by the parent AsyncGroup, so the end
+// function is always called before the parent AsyncGroup finishes waiting.
+type asyncGroupObserved struct {
+	parent  AsyncGroup
+	ctx     context.Context
+	end     func(err errs.Err)
+	mutex   sync.Mutex
+	pending int
+	err     errs.Err
+}
+
+func (ag *asyncGroupObserved) Add(fn func() errs.Err) {
+	ag.mutex.Lock()
+	ag.pending++
+	ag.mutex.Unlock()
+
+	ag.parent.Add(func() errs.Err {
+		err := fn()
+		ag.done(err)
+		return err
+	})
+}
+
+func (ag *asyncGroupObserved) context() context.Context {
+	return ag.ctx
+}
+
+func (ag *asyncGroupObserved) done(err errs.Err) {
+	ag.mutex.Lock()
+	if err.IsNotOk() && ag.err.IsOk() {
+		ag.err = err
+	}
+	ag.pending--
+	ended := (ag.pending == 0)
+	ag.mutex.Unlock()
+
+	if ended {
+		ag.end(ag.err)
+	}
+}
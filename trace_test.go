@@ -0,0 +1,140 @@
+package sabi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+type logTracer struct{}
+
+func (t logTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	Logs.PushBack("Start:" + name)
+	return ctx, logSpan{name: name}
+}
+
+type logSpan struct {
+	name string
+}
+
+func (s logSpan) SetAttr(key string, value any) {}
+
+func (s logSpan) End(err errs.Err) {
+	Logs.PushBack("End:" + s.name + ":" + err.ReasonName())
+}
+
+func TestSetTracer(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	assert.Equal(t, defaultRegistry.tracer, NoopTracer())
+
+	SetTracer(logTracer{})
+	assert.Equal(t, defaultRegistry.tracer, logTracer{})
+
+	SetTracer(nil)
+	assert.Equal(t, defaultRegistry.tracer, NoopTracer())
+
+	SetTracer(logTracer{})
+	Uses("foo", FooDaxSrc{})
+	assert.True(t, Setup().IsOk())
+	defer Close()
+
+	SetTracer(nil)
+	assert.Equal(t, defaultRegistry.tracer, logTracer{})
+
+	Logs.Init()
+
+	base := NewDaxBase()
+	defer base.Close()
+
+	err := Txn(base, func(dax Dax) errs.Err {
+		_, err := dax.getDaxConn("foo")
+		return err
+	})
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "Start:sabi.Txn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Start:sabi.Logic")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Start:sabi.CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.CreateDaxConn:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.Logic:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Start:sabi.Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.Commit:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.Txn:")
+	log = log.Next()
+	assert.Nil(t, log)
+
+	Reset()
+	assert.Equal(t, defaultRegistry.tracer, NoopTracer())
+}
+
+func TestSetTracer_twoPhaseCommit(t *testing.T) {
+	Reset()
+	defer Reset()
+	resetQux()
+	defer resetQux()
+
+	SetTracer(logTracer{})
+
+	err := runTwoPhaseTxn(t)
+	assert.True(t, err.IsOk())
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "End:sabi.Logic:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Start:sabi.Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux1#Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.Prepare:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Start:sabi.Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#Prepare")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.Prepare:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Start:sabi.Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.Commit:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Start:sabi.Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux1#CommitPrepared")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.Commit:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "Start:sabi.Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "qux2#CommitPrepared")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.Commit:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "End:sabi.Txn:")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
@@ -0,0 +1,165 @@
+// Copyright (C) 2023 Takayuki Sato. All Rights Reserved.
+// This program is free software under MIT License.
+// See the file LICENSE in this distribution for more details.
+
+package sabi
+
+import (
+	"github.com/sttk/sabi/errs"
+)
+
+// TxnHook is the struct type that has functions called at some points of the
+// lifecycle of a transaction.
+// Each field can be nil, and nil fields are just ignored.
+//
+// BeforeBegin is called before a transaction begins.
+// BeforeLogic and AfterLogic are called before and after each logic function
+// runs, with the index of the logic function and the errs.Err returned by it.
+// AfterCommit is called after all DaxConn(s) are committed successfully.
+// AfterRollback is called after DaxConn(s) are rollbacked, with the errs.Err
+// which caused the rollback.
+// AfterCommit and AfterRollback are not called for a nested transaction,
+// because its updates are committed or rollbacked with the outer transaction.
+// AfterEnd is called after a transaction ends, with the errs.Err which the
+// transaction function returns.
+// The argument daxConnNames is the registered names of DaxConn(s) used in the
+// transaction, in order of their creations.
+// OnCloseErr is called before AfterEnd if some DaxConn(s) failed to close at
+// the end of a transaction, with an errs.Err of the reason:
+// FailToCloseDaxConns.
+// Since DaxConn(s) are closed after the transaction is committed or
+// rollbacked, this errs.Err is not returned from the transaction function.
+type TxnHook struct {
+	BeforeBegin   func()
+	BeforeLogic   func(index int)
+	AfterLogic    func(index int, err errs.Err)
+	AfterCommit   func(daxConnNames []string)
+	AfterRollback func(daxConnNames []string, err errs.Err)
+	AfterEnd      func(daxConnNames []string, err errs.Err)
+	OnCloseErr    func(err errs.Err)
+}
+
+type txnHookEntry struct {
+	hook TxnHook
+	next *txnHookEntry
+}
+
+type txnHookList struct {
+	head *txnHookEntry
+	last *txnHookEntry
+}
+
+func (list *txnHookList) add(hook TxnHook) {
+	ent := &txnHookEntry{hook: hook}
+
+	if list.head == nil {
+		list.head = ent
+		list.last = ent
+	} else {
+		list.last.next = ent
+		list.last = ent
+	}
+}
+
+// AddTxnHook is the function that registers a TxnHook which is applied to
+// transactions of all DaxBase(s).
+// Global TxnHook(s) are called in order of registration, and before local
+// TxnHook(s) registered with DaxBase#AddTxnHook.
+//
+// Like Uses function, this function ignores adding new TxnHook(s) after Setup
+// or beginning of Txn.
+func AddTxnHook(hook TxnHook) {
+	defaultRegistry.AddTxnHook(hook)
+}
+
+// AddTxnHook is the method that registers a TxnHook which is applied to
+// transactions of all DaxBase(s) created by this Registry.
+// See AddTxnHook function for details.
+func (reg *Registry) AddTxnHook(hook TxnHook) {
+	if reg.isDaxSrcsFixed {
+		return
+	}
+
+	reg.txnHookList.add(hook)
+}
+
+func (base *daxBaseImpl) AddTxnHook(hook TxnHook) {
+	if base.isLocalDaxSrcsFixed {
+		return
+	}
+
+	base.localTxnHookList.add(hook)
+}
+
+func (base *daxBaseImpl) eachTxnHook(fn func(hook TxnHook)) {
+	for ent := base.registry.txnHookList.head; ent != nil; ent = ent.next {
+		fn(ent.hook)
+	}
+	for ent := base.localTxnHookList.head; ent != nil; ent = ent.next {
+		fn(ent.hook)
+	}
+}
+
+func (base *daxBaseImpl) daxConnNames() []string {
+	names := make([]string, 0, base.daxConnMap.Len())
+	for ent := base.daxConnMap.Front(); ent != nil; ent = ent.Next() {
+		names = append(names, ent.Key())
+	}
+	return names
+}
+
+func runBeforeBegin(base DaxBase) {
+	base.eachTxnHook(func(hook TxnHook) {
+		if hook.BeforeBegin != nil {
+			hook.BeforeBegin()
+		}
+	})
+}
+
+func runBeforeLogic(base DaxBase, index int) {
+	base.eachTxnHook(func(hook TxnHook) {
+		if hook.BeforeLogic != nil {
+			hook.BeforeLogic(index)
+		}
+	})
+}
+
+func runAfterLogic(base DaxBase, index int, err errs.Err) {
+	base.eachTxnHook(func(hook TxnHook) {
+		if hook.AfterLogic != nil {
+			hook.AfterLogic(index, err)
+		}
+	})
+}
+
+func runAfterCommit(base DaxBase, names []string) {
+	base.eachTxnHook(func(hook TxnHook) {
+		if hook.AfterCommit != nil {
+			hook.AfterCommit(names)
+		}
+	})
+}
+
+func runAfterRollback(base DaxBase, names []string, err errs.Err) {
+	base.eachTxnHook(func(hook TxnHook) {
+		if hook.AfterRollback != nil {
+			hook.AfterRollback(names, err)
+		}
+	})
+}
+
+func runAfterEnd(base DaxBase, names []string, err errs.Err) {
+	base.eachTxnHook(func(hook TxnHook) {
+		if hook.AfterEnd != nil {
+			hook.AfterEnd(names, err)
+		}
+	})
+}
+
+func runOnCloseErr(base DaxBase, err errs.Err) {
+	base.eachTxnHook(func(hook TxnHook) {
+		if hook.OnCloseErr != nil {
+			hook.OnCloseErr(err)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package sabi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sttk/sabi/errs"
+)
+
+func newLoggingTxnHook(prefix string) TxnHook {
+	return TxnHook{
+		BeforeBegin: func() {
+			Logs.PushBack(prefix + " BeforeBegin")
+		},
+		BeforeLogic: func(index int) {
+			Logs.PushBack(fmt.Sprintf("%s BeforeLogic %d", prefix, index))
+		},
+		AfterLogic: func(index int, err errs.Err) {
+			Logs.PushBack(fmt.Sprintf("%s AfterLogic %d %s", prefix, index, err.ReasonName()))
+		},
+		AfterCommit: func(names []string) {
+			Logs.PushBack(prefix + " AfterCommit " + strings.Join(names, ","))
+		},
+		AfterRollback: func(names []string, err errs.Err) {
+			Logs.PushBack(prefix + " AfterRollback " + strings.Join(names, ",") + " " + err.ReasonName())
+		},
+		AfterEnd: func(names []string, err errs.Err) {
+			Logs.PushBack(prefix + " AfterEnd " + strings.Join(names, ",") + " " + err.ReasonName())
+		},
+	}
+}
+
+func TestAddTxnHook_ok(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	AddTxnHook(TxnHook{})
+	assert.NotNil(t, defaultRegistry.txnHookList.head)
+	assert.Equal(t, defaultRegistry.txnHookList.head, defaultRegistry.txnHookList.last)
+
+	AddTxnHook(TxnHook{})
+	assert.Equal(t, defaultRegistry.txnHookList.head.next, defaultRegistry.txnHookList.last)
+	assert.Nil(t, defaultRegistry.txnHookList.last.next)
+}
+
+func TestAddTxnHook_ignoredAfterFixed(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	err := Setup()
+	assert.True(t, err.IsOk())
+	defer Close()
+
+	AddTxnHook(TxnHook{})
+	assert.Nil(t, defaultRegistry.txnHookList.head)
+}
+
+func TestTxn_txnHooks_committed(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	AddTxnHook(newLoggingTxnHook("global"))
+
+	func() {
+		base := NewDaxBase()
+		defer base.Close()
+
+		base.AddTxnHook(newLoggingTxnHook("local"))
+
+		err := base.Uses("database", FooDaxSrc{}).
+			IfOk(base.Uses_("file", &BarDaxSrc{}))
+		assert.True(t, err.IsOk())
+		Logs.Init()
+
+		err = Txn(base, func(dax any) errs.Err {
+			_, err := GetDaxConn[FooDaxConn](dax.(Dax), "database")
+			return err
+		}, func(dax any) errs.Err {
+			_, err := GetDaxConn[*BarDaxConn](dax.(Dax), "file")
+			return err
+		})
+		assert.True(t, err.IsOk())
+	}()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "global BeforeBegin")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local BeforeBegin")
+	log = log.Next()
+	assert.Equal(t, log.Value, "global BeforeLogic 0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local BeforeLogic 0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "global AfterLogic 0 ")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local AfterLogic 0 ")
+	log = log.Next()
+	assert.Equal(t, log.Value, "global BeforeLogic 1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local BeforeLogic 1")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "global AfterLogic 1 ")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local AfterLogic 1 ")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxConn#Commit")
+	log = log.Next()
+	assert.Equal(t, log.Value, "global AfterCommit database,file")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local AfterCommit database,file")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "global AfterEnd database,file ")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local AfterEnd database,file ")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "BarDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestTxn_txnHooks_rollbacked(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	type FailToDoSomething struct{}
+
+	func() {
+		base := NewDaxBase()
+		defer base.Close()
+
+		base.AddTxnHook(newLoggingTxnHook("local"))
+
+		err := base.Uses("database", FooDaxSrc{})
+		assert.True(t, err.IsOk())
+		Logs.Init()
+
+		err = Txn(base, func(dax any) errs.Err {
+			_, err := GetDaxConn[FooDaxConn](dax.(Dax), "database")
+			assert.True(t, err.IsOk())
+			return errs.New(FailToDoSomething{})
+		}, func(dax any) errs.Err {
+			return errs.Ok()
+		})
+		assert.IsType(t, err.Reason(), FailToDoSomething{})
+	}()
+
+	log := Logs.Front()
+	assert.Equal(t, log.Value, "local BeforeBegin")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local BeforeLogic 0")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#CreateDaxConn")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local AfterLogic 0 FailToDoSomething")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Rollback")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local AfterRollback database FailToDoSomething")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxConn#Close")
+	log = log.Next()
+	assert.Equal(t, log.Value, "local AfterEnd database FailToDoSomething")
+	log = log.Next()
+	assert.Equal(t, log.Value, "FooDaxSrc#Close")
+	log = log.Next()
+	assert.Nil(t, log)
+}
+
+func TestDax_AddTxnHook_doNothingWhileFixed(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	base := NewDaxBase().(*daxBaseImpl)
+	defer base.Close()
+
+	base.beginCtx(context.Background(), false)
+	base.AddTxnHook(TxnHook{})
+	assert.Nil(t, base.localTxnHookList.head)
+	base.end()
+
+	base.AddTxnHook(TxnHook{})
+	assert.NotNil(t, base.localTxnHookList.head)
+}